@@ -0,0 +1,19 @@
+package nodered_auth
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+)
+
+// NewCommand returns a cobra command for `auth` subcommands
+func NewCommand(cmdCli cli.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage the access token used to access a secured node-red admin api",
+	}
+	cmd.AddCommand(
+		NewLoginCommand(cmdCli),
+		NewLogoutCommand(cmdCli),
+	)
+	return cmd
+}
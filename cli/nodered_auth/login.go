@@ -0,0 +1,34 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_auth
+
+import (
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+)
+
+// loginCmd represents the login command
+func NewLoginCommand(ctx cli.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "login",
+		Short: "Request a new access token using the configured credentials",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			client, err := cli.NewClientWithoutRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			token, err := client.Login()
+			if err != nil {
+				return err
+			}
+			slog.Info("Logged in.", "expires", token.ExpiresAt)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_auth
+
+import (
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+)
+
+// logoutCmd represents the logout command
+func NewLogoutCommand(ctx cli.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout",
+		Short: "Revoke the cached access token",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			client, err := cli.NewClientWithoutRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			if err := client.Logout(); err != nil {
+				return err
+			}
+			slog.Info("Logged out.")
+			return nil
+		},
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_flow
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/utils"
+)
+
+// Files which are read from a bundle
+const (
+	BundleFlowsFile       = "flows.json"
+	BundleCredentialsFile = "flows_cred.json"
+	BundlePackageFile     = "package.json"
+	BundleManifestFile    = "manifest.json"
+)
+
+// Manifest contains the optional metadata of a bundle
+type Manifest struct {
+	Name        string `json:"name,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Bundle is a .tar.gz or .zip archive containing the flows along with the
+// credentials, required modules and metadata
+type Bundle struct {
+	Flows        []byte
+	Credentials  []byte
+	Dependencies map[string]string
+	Manifest     *Manifest
+}
+
+// IsBundle checks if the file is a bundle (rather than a plain flows file)
+func IsBundle(path string) (bool, error) {
+	format, err := utils.DetectArchiveFormat(path)
+	if err != nil {
+		return false, err
+	}
+	return format != utils.FormatUnknown, nil
+}
+
+// ReadBundle reads the files of a bundle. Only the flows file is required
+func ReadBundle(path string) (*Bundle, error) {
+	files, err := utils.ReadArchiveFiles(path, BundleFlowsFile, BundleCredentialsFile, BundlePackageFile, BundleManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle. path=%s. %w", path, err)
+	}
+
+	flows, ok := files[BundleFlowsFile]
+	if !ok {
+		return nil, fmt.Errorf("invalid bundle. missing %s. path=%s", BundleFlowsFile, path)
+	}
+
+	bundle := &Bundle{
+		Flows:        flows,
+		Credentials:  files[BundleCredentialsFile],
+		Dependencies: make(map[string]string),
+		Manifest:     &Manifest{},
+	}
+
+	if b, ok := files[BundlePackageFile]; ok {
+		pkg := struct {
+			Dependencies map[string]string `json:"dependencies"`
+		}{}
+		if err := json.Unmarshal(b, &pkg); err != nil {
+			return nil, fmt.Errorf("invalid bundle. invalid %s. %w", BundlePackageFile, err)
+		}
+		for name, version := range pkg.Dependencies {
+			// The core nodes are always installed
+			if name == nodered.CoreModule {
+				continue
+			}
+			bundle.Dependencies[name] = version
+		}
+	}
+
+	if b, ok := files[BundleManifestFile]; ok {
+		if err := json.Unmarshal(b, bundle.Manifest); err != nil {
+			return nil, fmt.Errorf("invalid bundle. invalid %s. %w", BundleManifestFile, err)
+		}
+	}
+	return bundle, nil
+}
@@ -1,19 +1,35 @@
 package nodered_flow
 
 import (
+	"context"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+const (
+	// InstallModeMerge only replaces the flows belonging to the module being installed
+	InstallModeMerge = "merge"
+	// InstallModeReplace replaces all of the deployed flows with the module being installed
+	InstallModeReplace = "replace"
 )
 
-func GetAPI() string {
-	v := viper.GetString("nodered.api")
+func GetInstallMode() string {
+	v := viper.GetString("nodered.flows.mode")
 	if v == "" {
-		v = "http://127.0.0.1:1880"
+		v = InstallModeMerge
 	}
 	return v
 }
 
+// GetRollbackEnabled checks if the previous flows should be restored when a deployment fails (enabled by default)
+func GetRollbackEnabled() bool {
+	key := "nodered.flows.rollback.enabled"
+	return !viper.IsSet(key) || viper.GetBool(key)
+}
+
 // NewCommand returns a cobra command for `nodered-flows` subcommands
 func NewCommand(cmdCli cli.Cli) *cobra.Command {
 	cmd := &cobra.Command{
@@ -27,6 +43,18 @@ func NewCommand(cmdCli cli.Cli) *cobra.Command {
 		NewUpdateListCommand(cmdCli),
 		NewListCommand(cmdCli),
 		NewFinalizeCommand(cmdCli),
+		NewRollbackCommand(cmdCli),
+		cli.NewDriftCommand(SoftwareType, func(ctx context.Context) ([]registry.LiveModule, error) {
+			client, err := cli.NewClientWithRetries(ctx, cli.GetAPI())
+			if err != nil {
+				return nil, err
+			}
+			doc, err := client.GetFlowDocument()
+			if err != nil {
+				return nil, err
+			}
+			return LiveModules(doc.Flows), nil
+		}),
 	)
 	return cmd
 }
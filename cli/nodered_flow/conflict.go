@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+const (
+	// ConflictPolicyMerge re-applies the changes to the latest flows if the flows were changed during the deployment
+	ConflictPolicyMerge = "merge"
+	// ConflictPolicyFail fails the deployment if the flows were changed during the deployment
+	ConflictPolicyFail = "fail"
+)
+
+// Maximum number of deployment attempts when the flows keep changing during the deployment
+const maxConflictAttempts = 3
+
+// GetConflictPolicy returns how to handle flows which were changed (e.g. in the node-red editor) while being deployed
+func GetConflictPolicy() (string, error) {
+	v := viper.GetString("nodered.flows.conflict_policy")
+	switch v {
+	case "":
+		return ConflictPolicyMerge, nil
+	case ConflictPolicyMerge, ConflictPolicyFail:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy. policy=%s", v)
+	}
+}
+
+// RebuildFunc applies the changes of an operation to the given flows
+type RebuildFunc func(current []nodered.Node) ([]nodered.Node, error)
+
+// LogEditorChanges logs the nodes which were changed since the base flows were read, and if the
+// change is preserved or overwritten by the flows being deployed. A change is preserved if the
+// deployed node is the same as the changed node, or if a deleted node is not deployed again
+func LogEditorChanges(base []nodered.Node, latest []nodered.Node, flows []nodered.Node) {
+	baseIndex := nodered.NodeIndex(base)
+	latestIndex := nodered.NodeIndex(latest)
+	if normalized, err := normalizeNodes(flows); err == nil {
+		// The credentials of the deployed nodes are not included in the latest flows
+		flows = normalized
+	}
+	flowsIndex := nodered.NodeIndex(flows)
+
+	changed := nodered.ChangedNodes(base, latest)
+	ids := sortedIDs(changed)
+	preserved := 0
+	for _, id := range ids {
+		node, exists := latestIndex[id]
+		if !exists {
+			node = baseIndex[id]
+		}
+		deployed, deploying := flowsIndex[id]
+
+		kept := !deploying
+		if exists {
+			kept = deploying && reflect.DeepEqual(deployed, node)
+		}
+		attrs := []any{"id", id, "type", node.Type(), "name", node.GetString("name"), "deleted", !exists}
+		if kept {
+			preserved++
+			slog.Info("Editor change was preserved.", attrs...)
+		} else {
+			slog.Warn("Editor change was overwritten.", attrs...)
+		}
+	}
+	slog.Info("Merged editor changes.", "changed", len(ids), "preserved", preserved, "overwritten", len(ids)-preserved)
+}
+
+// isDeployed checks if the given flows are already deployed, e.g. when a deployment request
+// was retried after node-red processed it, so the retry was rejected due to the new revision
+func isDeployed(latest []nodered.Node, flows []nodered.Node) bool {
+	normalized, err := normalizeNodes(flows)
+	if err != nil {
+		slog.Debug("Could not normalize flows.", "err", err)
+		return false
+	}
+	return len(nodered.ChangedNodes(normalized, latest)) == 0
+}
+
+// normalizeNodes returns a copy of the nodes as node-red returns them from GET /flows,
+// which never includes the credentials of the nodes
+func normalizeNodes(nodes []nodered.Node) ([]nodered.Node, error) {
+	b, err := json.Marshal(nodes)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]nodered.Node, 0, len(nodes))
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	for _, node := range out {
+		delete(node, "credentials")
+	}
+	return out, nil
+}
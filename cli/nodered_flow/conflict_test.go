@@ -0,0 +1,49 @@
+package nodered_flow
+
+import (
+	"testing"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+func TestIsDeployed(t *testing.T) {
+	deployed := func() []nodered.Node {
+		return []nodered.Node{
+			{"id": "t1", "type": "tab", "label": "flow"},
+			{"id": "n1", "type": "mqtt-broker", "port": float64(1883)},
+		}
+	}
+	withCredentials := func() []nodered.Node {
+		nodes := deployed()
+		nodes[1]["credentials"] = map[string]any{"user": "device", "password": "secret"}
+		return nodes
+	}
+
+	tests := []struct {
+		name   string
+		latest []nodered.Node
+		flows  []nodered.Node
+		want   bool
+	}{
+		{name: "same flows", latest: deployed(), flows: deployed(), want: true},
+		{name: "credentials are not returned by node-red", latest: deployed(), flows: withCredentials(), want: true},
+		{name: "node changed", latest: []nodered.Node{{"id": "t1", "type": "tab", "label": "edited"}, deployed()[1]}, flows: deployed(), want: false},
+		{name: "node added", latest: append(deployed(), nodered.Node{"id": "n2", "type": "inject", "z": "t1"}), flows: deployed(), want: false},
+		{name: "node removed", latest: deployed()[:1], flows: deployed(), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDeployed(tt.latest, tt.flows); got != tt.want {
+				t.Errorf("isDeployed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("flows are not modified", func(t *testing.T) {
+		flows := withCredentials()
+		isDeployed(deployed(), flows)
+		if _, ok := flows[1]["credentials"]; !ok {
+			t.Errorf("isDeployed() removed the credentials of the flows")
+		}
+	})
+}
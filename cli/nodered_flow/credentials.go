@@ -0,0 +1,153 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_flow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+var ErrEncryptedCredentials = errors.New("encrypted credentials can only be deployed when using the replace install mode")
+
+// DefaultCredentialsFile is the device local file containing credentials of nodes, which is
+// managed outside of the artifacts (e.g. by the thin-edge.io configuration management)
+const DefaultCredentialsFile = "/etc/tedge/plugins/tedge-nodered-plugin-credentials.json"
+
+// Credentials of nodes, where the key is the node id
+type Credentials map[string]map[string]any
+
+func GetCredentialsFile() string {
+	viper.SetDefault("nodered.flows.credentials.file", DefaultCredentialsFile)
+	return viper.GetString("nodered.flows.credentials.file")
+}
+
+// GetSidecarCredentialsPath returns the path of the credentials file which belongs to a
+// flows file, using the node-red naming convention, e.g. flows.json => flows_cred.json
+func GetSidecarCredentialsPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_cred" + ext
+}
+
+// ParseCredentials parses the contents of a credentials file. The file either contains
+// the credentials of each node, or the encrypted credentials of all nodes ({"$": "..."})
+func ParseCredentials(b []byte) (Credentials, string, error) {
+	data := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &data); err != nil {
+		// Don't include the parser error as it can contain parts of the file
+		return nil, "", errors.New("invalid credentials file. expected a json object")
+	}
+
+	if raw, ok := data["$"]; ok {
+		var encrypted string
+		if err := json.Unmarshal(raw, &encrypted); err != nil || len(data) > 1 {
+			return nil, "", errors.New("invalid encrypted credentials")
+		}
+		return nil, encrypted, nil
+	}
+
+	credentials := make(Credentials)
+	for id, raw := range data {
+		values := make(map[string]any)
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, "", fmt.Errorf("invalid credentials. expected a json object. id=%s", id)
+		}
+		credentials[id] = values
+	}
+	return credentials, "", nil
+}
+
+// ReadCredentialsFile reads a credentials file. A missing file is not treated as an error
+func ReadCredentialsFile(path string) (Credentials, string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o077 != 0 {
+		slog.Warn("Credentials file is accessible by other users.", "path", path, "mode", info.Mode().Perm())
+	}
+	credentials, encrypted, err := ParseCredentials(b)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w. path=%s", err, path)
+	}
+	return credentials, encrypted, nil
+}
+
+// ReadDeviceCredentials reads the credentials which are managed on the device. Encrypted
+// credentials are not supported, as the credentials are shared by all modules
+func ReadDeviceCredentials() (Credentials, error) {
+	path := GetCredentialsFile()
+	if path == "" {
+		return nil, nil
+	}
+	credentials, encrypted, err := ReadCredentialsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted != "" {
+		return nil, fmt.Errorf("encrypted credentials are not supported in the device credentials file. path=%s", path)
+	}
+	return credentials, nil
+}
+
+// AttachCredentials sets the credentials of each node. Only the node ids are logged
+func AttachCredentials(nodes []nodered.Node, credentials Credentials) {
+	if len(credentials) == 0 {
+		return
+	}
+	attached := make([]string, 0)
+	for _, node := range nodes {
+		if values, ok := credentials[node.ID()]; ok {
+			node["credentials"] = values
+			attached = append(attached, node.ID())
+		}
+	}
+	if len(attached) == 0 {
+		return
+	}
+	slices.Sort(attached)
+	slog.Info("Attached credentials to nodes.", "ids", strings.Join(attached, ","))
+}
+
+// MergeCredentials merges the credentials of each node, where the values of later sources take precedence
+func MergeCredentials(sources ...Credentials) Credentials {
+	out := make(Credentials)
+	for _, source := range sources {
+		for id, values := range source {
+			if _, ok := out[id]; !ok {
+				out[id] = make(map[string]any)
+			}
+			maps.Copy(out[id], values)
+		}
+	}
+	return out
+}
+
+// CheckCredentialsMode checks if the encrypted credentials can be deployed using the install mode
+func CheckCredentialsMode(mode string, encrypted string) error {
+	if encrypted != "" && mode != InstallModeReplace {
+		return fmt.Errorf("%w. mode=%s", ErrEncryptedCredentials, mode)
+	}
+	return nil
+}
+
+// EncryptedCredentialsBody returns the credentials to include in the deployment request
+func EncryptedCredentialsBody(encrypted string) any {
+	if encrypted == "" {
+		return nil
+	}
+	return map[string]string{"$": encrypted}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_flow
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+// GetDependencyCheckEnabled checks if the node types used by the flows should be checked before deploying them
+func GetDependencyCheckEnabled() bool {
+	viper.SetDefault("nodered.flows.dependencies.check", true)
+	return viper.GetBool("nodered.flows.dependencies.check")
+}
+
+// GetDependencyInstallEnabled checks if missing modules should be installed before deploying the flows
+func GetDependencyInstallEnabled() bool {
+	return viper.GetBool("nodered.flows.dependencies.install")
+}
+
+// LookupTypeModule returns the module which provides a node type. User defined
+// mappings take precedence over the built-in ones
+func LookupTypeModule(nodeType string) (string, bool) {
+	// viper lower cases the keys, so the node types have to be compared case insensitively
+	for k, v := range viper.GetStringMapString("nodered.flows.dependencies.types") {
+		if strings.EqualFold(k, nodeType) {
+			return v, true
+		}
+	}
+	return nodered.LookupTypeModule(nodeType)
+}
+
+// CheckDependencies checks that all of the node types used by the modules are registered in node-red.
+// The dependencies which are required by the modules are always installed, and if enabled,
+// the modules which are declared by the artifacts or which provide the missing types are also installed
+func CheckDependencies(client *nodered.Client, modules []*Module) error {
+	nodes := make([]nodered.Node, 0)
+	dependencies := make(map[string]string)
+	required := make(map[string]string)
+	for _, module := range modules {
+		nodes = append(nodes, module.Nodes...)
+		maps.Copy(dependencies, module.Dependencies)
+		if module.RequireDependencies {
+			maps.Copy(required, module.Dependencies)
+		}
+	}
+
+	if len(required) > 0 {
+		if err := installModules(client, required); err != nil {
+			return err
+		}
+	}
+
+	if !GetDependencyCheckEnabled() || len(nodes) == 0 {
+		return nil
+	}
+
+	registered, err := client.GetNodeTypes()
+	if err != nil {
+		return err
+	}
+	missing := nodered.MissingTypes(nodes, registered)
+	if len(missing) == 0 {
+		return nil
+	}
+	slog.Info("Flows use node types which are not installed.", "types", strings.Join(missing, ","))
+
+	if !GetDependencyInstallEnabled() {
+		return fmt.Errorf("%w. types=%s", nodered.ErrMissingTypes, strings.Join(missing, ","))
+	}
+
+	// Prefer the declared dependencies as they include the version
+	pending := maps.Clone(dependencies)
+	for _, nodeType := range missing {
+		name, ok := LookupTypeModule(nodeType)
+		if !ok {
+			continue
+		}
+		if _, ok := pending[name]; !ok {
+			pending[name] = ""
+		}
+	}
+	if err := installModules(client, pending); err != nil {
+		return err
+	}
+
+	registered, err = client.GetNodeTypes()
+	if err != nil {
+		return err
+	}
+	if missing := nodered.MissingTypes(nodes, registered); len(missing) > 0 {
+		return fmt.Errorf("%w. types=%s", nodered.ErrMissingTypes, strings.Join(missing, ","))
+	}
+	return nil
+}
+
+// installModules installs the given modules (name => version) which are not already installed
+func installModules(client *nodered.Client, modules map[string]string) error {
+	installed, err := client.GetNodeModules()
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(modules)) {
+		if slices.ContainsFunc(installed, func(m nodered.NodeModule) bool { return m.Name == name }) {
+			continue
+		}
+		slog.Info("Installing missing module.", "name", name, "version", modules[name])
+		module, err := client.InstallNodeModule(name, modules[name])
+		if err != nil {
+			return fmt.Errorf("could not install module. name=%s. %w", name, err)
+		}
+		slog.Info("Installed module.", "name", module.Name, "version", module.Version)
+	}
+	return nil
+}
@@ -5,14 +5,15 @@ package nodered_flow
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -48,56 +49,226 @@ func (c *InstallCommand) RunE(cmd *cobra.Command, args []string) error {
 
 	moduleName := args[0]
 
-	client := nodered.NewClientWithRetries(GetAPI())
+	client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+	if err != nil {
+		return err
+	}
 
-	file, err := os.Open(c.File)
+	module, err := ReadModule(c.File, moduleName, c.ModuleVersion)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	var flowsIn any
-	b, err := io.ReadAll(file)
+	if err := CheckDependencies(client, []*Module{module}); err != nil {
+		return err
+	}
+
+	current, err := client.GetFlowDocument()
 	if err != nil {
 		return err
 	}
 
-	// Edit the flow configuration and add the flow name and version to it
-	node := gjson.ParseBytes(b)
-	flowIndexes := make([]int64, 0)
-	if node.IsArray() {
-		node.ForEach(func(key, value gjson.Result) bool {
-			if value.Get("type").String() == "tab" {
-				flowIndexes = append(flowIndexes, key.Int())
-			}
-			return true
-		})
+	mode := GetInstallMode()
+	if err := CheckCredentialsMode(mode, module.EncryptedCredentials); err != nil {
+		return err
+	}
+	if mode == InstallModeMerge {
+		slog.Info("Merging module into existing flows.", "name", moduleName, "rev", current.Rev)
 	}
 
-	var ob []byte
-	for _, i := range flowIndexes {
-		ob, err = sjson.SetBytes(b, fmt.Sprintf("%d.env.-1", i), nodered.FlowEnv{Name: "MODULE_NAME", Value: moduleName, Type: "str"})
+	build := func(flows []nodered.Node) ([]nodered.Node, error) {
+		return InstallModule(mode, flows, moduleName, module.Nodes)
+	}
+	flowsIn, err := build(current.Flows)
+	if err != nil {
+		return err
+	}
+
+	deployed, err := Deploy(client, current, flowsIn, build, EncryptedCredentialsBody(module.EncryptedCredentials), []string{moduleName})
+	if err != nil {
+		return err
+	}
+
+	RecordDeployment(deployed.Flows, []*Module{module})
+	slog.Info("New revision.", "rev", deployed.Rev)
+	return nil
+}
+
+// Module is a flows artifact which is installed as a single module
+type Module struct {
+	Name    string
+	Version string
+
+	// Checksum of the artifact
+	Checksum string
+
+	Nodes []nodered.Node
+
+	// Modules (and their versions) which provide the node types used by the flows
+	Dependencies map[string]string
+
+	// Install the dependencies even if installing missing modules is not enabled,
+	// e.g. the dependencies are declared in the package.json of a bundle
+	RequireDependencies bool
+
+	// Credentials of the nodes which are deployed along with the nodes
+	Credentials Credentials
+
+	// Encrypted credentials of all of the nodes, which can only be deployed in replace mode
+	EncryptedCredentials string
+
+	Manifest *Manifest
+}
+
+// ReadModule reads a flows file and adds the module name and version to each of its tabs.
+// The file is either a json array of nodes, an object with the nodes under the "flows"
+// property and the required modules under the "dependencies" property, or a bundle
+// (.tar.gz or .zip) containing a flows.json file
+func ReadModule(path string, moduleName string, moduleVersion string) (*Module, error) {
+	checksum, err := registry.Checksum(path)
+	if err != nil {
+		return nil, err
+	}
+	module := &Module{
+		Name:         moduleName,
+		Version:      moduleVersion,
+		Checksum:     checksum,
+		Dependencies: make(map[string]string),
+		Manifest:     &Manifest{},
+	}
+
+	isBundle, err := IsBundle(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	var credentials []byte
+	if isBundle {
+		bundle, err := ReadBundle(path)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		b = bundle.Flows
+		module.Dependencies = bundle.Dependencies
+		module.RequireDependencies = len(bundle.Dependencies) > 0
+		credentials = bundle.Credentials
+		module.Manifest = bundle.Manifest
+
+		slog.Info("Read bundle.", "path", path, "description", module.Manifest.Description, "dependencies", len(module.Dependencies))
+		if module.Manifest.Name != "" && module.Manifest.Name != moduleName {
+			slog.Warn("Bundle name does not match the module name.", "name", moduleName, "bundle", module.Manifest.Name)
+		}
+		if module.Manifest.Version != "" && moduleVersion != "" && module.Manifest.Version != moduleVersion {
+			slog.Warn("Bundle version does not match the module version.", "version", moduleVersion, "bundle", module.Manifest.Version)
 		}
-		b = ob
-		ob, err = sjson.SetBytes(b, fmt.Sprintf("%d.env.-1", i), nodered.FlowEnv{Name: "MODULE_VERSION", Value: c.ModuleVersion, Type: "str"})
+	} else {
+		b, err = os.ReadFile(path)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		credentials, err = os.ReadFile(GetSidecarCredentialsPath(path))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
 		}
-		b = ob
 	}
 
-	err = json.Unmarshal(ob, &flowsIn)
+	var artifactCredentials Credentials
+	if len(credentials) > 0 {
+		artifactCredentials, module.EncryptedCredentials, err = ParseCredentials(credentials)
+		if err != nil {
+			return nil, err
+		}
+	}
+	deviceCredentials, err := ReadDeviceCredentials()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	module.Credentials = MergeCredentials(artifactCredentials, deviceCredentials)
 
-	resp, err := client.SetFlow("", flowsIn)
+	b, err = ResolveVariables(b)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	slog.Info("New revision.", "rev", resp.Rev)
-	return nil
+	node := gjson.ParseBytes(b)
+	if node.IsObject() {
+		node.Get("dependencies").ForEach(func(key, value gjson.Result) bool {
+			module.Dependencies[key.String()] = value.String()
+			return true
+		})
+		node = node.Get("flows")
+		b = []byte(node.Raw)
+	}
+	if !node.IsArray() {
+		return nil, fmt.Errorf("invalid flows file. expected a json array. path=%s", path)
+	}
+
+	// Edit the flow configuration and add the flow name and version to it
+	flowIndexes := make([]int64, 0)
+	node.ForEach(func(key, value gjson.Result) bool {
+		if value.Get("type").String() == "tab" {
+			flowIndexes = append(flowIndexes, key.Int())
+		}
+		return true
+	})
+	if len(flowIndexes) == 0 {
+		return nil, fmt.Errorf("invalid flows file. no tabs found. path=%s", path)
+	}
+
+	env := []nodered.FlowEnv{
+		{Name: "MODULE_NAME", Value: moduleName, Type: "str"},
+		{Name: "MODULE_VERSION", Value: moduleVersion, Type: "str"},
+	}
+	if GetTedgeEnvEnabled() {
+		env = append(env, TedgeEnv()...)
+	}
+
+	for _, i := range flowIndexes {
+		for _, item := range env {
+			b, err = setTabEnv(b, i, item)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := json.Unmarshal(b, &module.Nodes); err != nil {
+		return nil, err
+	}
+	AttachCredentials(module.Nodes, module.Credentials)
+	return module, nil
+}
+
+// setTabEnv sets an environment variable of a tab. Existing entries with the same name are
+// replaced (e.g. the tabs of flows which were exported from a device), otherwise it is appended
+func setTabEnv(b []byte, tabIndex int64, item nodered.FlowEnv) ([]byte, error) {
+	path := fmt.Sprintf("%d.env", tabIndex)
+	replaced := false
+	var err error
+	gjson.GetBytes(b, path).ForEach(func(key, value gjson.Result) bool {
+		if value.Get("name").String() != item.Name {
+			return true
+		}
+		b, err = sjson.SetBytes(b, fmt.Sprintf("%s.%d", path, key.Int()), item)
+		replaced = true
+		return err == nil
+	})
+	if err != nil || replaced {
+		return b, err
+	}
+	return sjson.SetBytes(b, path+".-1", item)
+}
+
+// InstallModule returns the flows after installing the module's nodes using the given install mode
+func InstallModule(mode string, current []nodered.Node, moduleName string, nodes []nodered.Node) ([]nodered.Node, error) {
+	switch mode {
+	case InstallModeMerge:
+		return nodered.MergeModule(current, moduleName, nodes)
+	case InstallModeReplace:
+		slog.Info("Replacing all existing flows.", "name", moduleName)
+		return nodes, nil
+	default:
+		return nil, fmt.Errorf("invalid install mode. mode=%s", mode)
+	}
 }
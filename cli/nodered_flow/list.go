@@ -6,11 +6,9 @@ package nodered_flow
 import (
 	"fmt"
 	"log/slog"
-	"sort"
 
 	"github.com/spf13/cobra"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
-	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
 )
 
 // listCmd represents the list command
@@ -22,30 +20,41 @@ func NewListCommand(cliContext cli.Cli) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
 
-			client := nodered.NewClientWithoutRetries(GetAPI())
-			resp, err := client.GetFlows()
+			client, err := cli.NewClientWithoutRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			doc, err := client.GetFlowDocument()
 			if err != nil {
 				// Don't fail the API is not ready yet
 				slog.Warn("nodered api is not yet available.", "err", err)
 				return nil
 			}
-			flowIndexes := make(map[string]int)
-			for i, flow := range resp {
-				flowIndexes[flow.GetName()] = i
-			}
-
-			keys := make([]string, 0, len(flowIndexes))
-			for key := range flowIndexes {
-				keys = append(keys, key)
-			}
-			sort.Strings(keys)
 
-			for _, name := range keys {
-				flow := resp[flowIndexes[name]]
-				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", name, flow.GetVersion())
+			live := LiveModules(doc.Flows)
+			installed, _ := cli.ReconcileRegistry(SoftwareType, live)
+			for _, module := range live {
+				// Prefer the version from the flows, as it is also updated by imports in the editor
+				version := module.Version
+				if version == "" {
+					if entry, ok := installed.Get(SoftwareType, module.Name); ok {
+						version = entry.Version
+					}
+				}
+				if version == "" {
+					version = shortRevision(doc.Rev)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", module.Name, version)
 			}
 
 			return nil
 		},
 	}
 }
+
+func shortRevision(rev string) string {
+	if len(rev) > 8 {
+		return rev[0:8]
+	}
+	return rev
+}
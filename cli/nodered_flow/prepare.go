@@ -15,8 +15,15 @@ func NewPrepareCommand(ctx cli.Cli) *cobra.Command {
 	return &cobra.Command{
 		Use:   "prepare",
 		Short: "Prepare for install/removal",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			// Don't start the operation while node-red is still starting
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			return cli.WaitForReady(client)
 		},
 	}
 }
@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_flow
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+// SoftwareType is the software type used to record the flows in the registry
+const SoftwareType = "nodered-flows"
+
+func sortedIDs(ids map[string]struct{}) []string {
+	out := make([]string, 0, len(ids))
+	for id := range ids {
+		out = append(out, id)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// LiveModules returns the modules of the deployed flows sorted by name
+func LiveModules(flows []nodered.Node) []registry.LiveModule {
+	versions := make(map[string]string)
+	for _, node := range flows {
+		if !node.IsTab() {
+			continue
+		}
+		name := node.GetModuleName()
+		if _, ok := versions[name]; !ok || versions[name] == "" {
+			versions[name] = node.GetModuleVersion()
+		}
+	}
+
+	modules := make([]registry.LiveModule, 0, len(versions))
+	for name, version := range versions {
+		modules = append(modules, registry.LiveModule{
+			Name:    name,
+			Version: version,
+			Nodes:   sortedIDs(nodered.ModuleNodes(flows, name)),
+		})
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+	return modules
+}
+
+// RecordDeployment updates the registry after the flows were deployed. The installed modules are
+// recorded along with the nodes they own, and modules which are no longer deployed are removed
+func RecordDeployment(flows []nodered.Node, installed []*Module) {
+	cli.UpdateRegistry(func(r *registry.Registry) {
+		for _, module := range installed {
+			r.Put(SoftwareType, &registry.Module{
+				Name:     module.Name,
+				Version:  module.Version,
+				Checksum: module.Checksum,
+				Nodes:    sortedIDs(nodered.ModuleNodes(flows, module.Name)),
+			})
+		}
+		r.Reconcile(SoftwareType, LiveModules(flows))
+	})
+}
+
+// RegisteredNodes returns the nodes which the registry recorded for a module and which are
+// still deployed. This finds the nodes of a module even if its tabs were renamed in the editor
+func RegisteredNodes(flows []nodered.Node, name string, version string) map[string]struct{} {
+	owned := make(map[string]struct{})
+	r, err := cli.OpenRegistry()
+	if err != nil {
+		return owned
+	}
+	module, ok := r.Get(SoftwareType, name)
+	if !ok || (version != "" && module.Version != version) {
+		return owned
+	}
+	for _, node := range flows {
+		if slices.Contains(module.Nodes, node.ID()) {
+			owned[node.ID()] = struct{}{}
+		}
+	}
+	return owned
+}
+
+// ModuleNodesToRemove returns the nodes owned by a module, falling back to the nodes recorded in the registry
+func ModuleNodesToRemove(flows []nodered.Node, name string, version string) map[string]struct{} {
+	owned := nodered.ModuleVersionNodes(flows, name, version)
+	if len(owned) == 0 {
+		owned = RegisteredNodes(flows, name, version)
+	}
+	return owned
+}
@@ -4,7 +4,7 @@ Copyright © 2024 thin-edge.io <info@thin-edge.io>
 package nodered_flow
 
 import (
-	"errors"
+	"fmt"
 	"log/slog"
 
 	"github.com/spf13/cobra"
@@ -22,25 +22,43 @@ type RemoveCommand struct {
 func NewRemoveCommand(ctx cli.Cli) *cobra.Command {
 	command := &RemoveCommand{}
 	cmd := &cobra.Command{
-		Use:   "remove",
+		Use:   "remove <MODULE_NAME>",
 		Short: "Remove flows",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+			moduleName := args[0]
 
-			client := nodered.NewClientWithRetries(GetAPI())
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
 
-			flows, err := client.GetFlows()
+			current, err := client.GetFlowDocument()
 			if err != nil {
 				return err
 			}
-			errs := make([]error, 0)
-			for _, flow := range flows {
-				slog.Info("Removing flow.", "id", flow.ID)
-				err := client.DeleteFlow(flow.ID)
-				errs = append(errs, err)
+
+			build := func(flows []nodered.Node) ([]nodered.Node, error) {
+				owned := ModuleNodesToRemove(flows, moduleName, command.ModuleVersion)
+				if len(owned) == 0 {
+					return nil, fmt.Errorf("%w. name=%s, version=%s", nodered.ErrModuleNotInstalled, moduleName, command.ModuleVersion)
+				}
+				return nodered.RemoveNodes(flows, owned), nil
+			}
+			flows, err := build(current.Flows)
+			if err != nil {
+				return err
+			}
+			slog.Info("Removing module.", "name", moduleName, "version", command.ModuleVersion, "nodes", len(current.Flows)-len(flows))
+
+			deployed, err := Deploy(client, current, flows, build, nil, nil)
+			if err != nil {
+				return err
 			}
-			return errors.Join(errs...)
+			RecordDeployment(deployed.Flows, nil)
+			slog.Info("New revision.", "rev", deployed.Rev)
+			return nil
 		},
 	}
 	cmd.Flags().StringVar(&command.ModuleVersion, "module-version", "", "Software version to remove")
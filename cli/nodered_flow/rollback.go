@@ -0,0 +1,219 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/utils"
+)
+
+var ErrPostDeployCheck = errors.New("post deployment check failed")
+var ErrFlowsChanged = errors.New("flows were changed after the deployment, so they were not rolled back")
+
+// GetSnapshotPath returns the path to the snapshot of the flows which were deployed before the last deployment
+func GetSnapshotPath() string {
+	return filepath.Join(cli.GetStateDir(), "nodered-flows", "snapshot.json")
+}
+
+func SaveSnapshot(doc *nodered.FlowDocument) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(GetSnapshotPath(), b, 0600)
+}
+
+func LoadSnapshot() (*nodered.FlowDocument, error) {
+	b, err := os.ReadFile(GetSnapshotPath())
+	if err != nil {
+		return nil, err
+	}
+	doc := &nodered.FlowDocument{}
+	if err := json.Unmarshal(b, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// RestoreSnapshot deploys the flows of a snapshot, regardless of the current revision
+func RestoreSnapshot(client *nodered.Client, snapshot *nodered.FlowDocument) (*nodered.FlowResponseV2, error) {
+	return RestoreSnapshotContext(client.Context(), client, snapshot)
+}
+
+// RestoreSnapshotContext is the same as RestoreSnapshot, but uses the given context
+func RestoreSnapshotContext(ctx context.Context, client *nodered.Client, snapshot *nodered.FlowDocument) (*nodered.FlowResponseV2, error) {
+	slog.Info("Restoring flows snapshot.", "rev", snapshot.Rev, "nodes", len(snapshot.Flows))
+	return client.SetFlowContext(ctx, "", snapshot.Flows)
+}
+
+// GetRollbackTimeout returns the maximum duration of restoring the snapshot after a failed deployment
+func GetRollbackTimeout() time.Duration {
+	viper.SetDefault("nodered.flows.rollback.timeout", 30*time.Second)
+	return viper.GetDuration("nodered.flows.rollback.timeout")
+}
+
+// rollbackContext returns the context used to restore the snapshot. It is not cancelled along with
+// the operation (e.g. on SIGTERM or when the operation timeout is reached), as otherwise the new
+// flows would be left deployed even though the operation failed
+func rollbackContext(client *nodered.Client) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(client.Context()), GetRollbackTimeout())
+}
+
+// Deploy deploys the new flows after saving a snapshot of the current flows.
+// The revision of the current flows is sent along with the flows, so node-red rejects the deployment
+// if the flows were changed in the meantime (e.g. in the node-red editor). Depending on the conflict policy,
+// the changes are then applied to the latest flows using rebuild, or the deployment fails.
+// If the post deployment check fails, or the outcome of the deployment is unknown (e.g. the connection
+// was lost), then the snapshot is restored
+func Deploy(client *nodered.Client, current *nodered.FlowDocument, flows []nodered.Node, rebuild RebuildFunc, credentials any, modules []string) (*nodered.FlowDocument, error) {
+	policy, err := GetConflictPolicy()
+	if err != nil {
+		return nil, err
+	}
+	rollback := GetRollbackEnabled()
+
+	for attempt := 1; ; attempt++ {
+		if rollback {
+			if err := SaveSnapshot(current); err != nil {
+				return nil, fmt.Errorf("could not save flows snapshot. %w", err)
+			}
+		}
+
+		rev := ""
+		resp, err := client.SetFlowWithCredentials(current.Rev, flows, credentials)
+		if err == nil {
+			rev = resp.Rev
+		} else if errors.Is(err, nodered.ErrConflict) {
+			latest, fetchErr := client.GetFlowDocument()
+			if fetchErr != nil {
+				return nil, errors.Join(err, fetchErr)
+			}
+			if !isDeployed(latest.Flows, flows) {
+				changed := sortedIDs(nodered.ChangedNodes(current.Flows, latest.Flows))
+				if policy != ConflictPolicyMerge || rebuild == nil || attempt >= maxConflictAttempts {
+					// Nothing was deployed, so the snapshot must not be restored as it would revert the other changes
+					slog.Error("Flows were changed during the deployment.", "rev", current.Rev, "latest", latest.Rev, "changed", changed, "policy", policy, "attempt", attempt)
+					return nil, err
+				}
+				slog.Warn("Flows were changed during the deployment, applying the changes to the latest flows.", "rev", current.Rev, "latest", latest.Rev, "changed", len(changed), "attempt", attempt)
+				next, err := rebuild(latest.Flows)
+				if err != nil {
+					return nil, fmt.Errorf("could not apply the changes to the latest flows. %w", err)
+				}
+				LogEditorChanges(current.Flows, latest.Flows, next)
+				current, flows = latest, next
+				continue
+			}
+			// The request was retried after node-red had already deployed the flows
+			slog.Info("Flows are already deployed.", "rev", latest.Rev)
+			rev, err = latest.Rev, nil
+		}
+
+		if err == nil && rollback {
+			err = CheckDeployment(client, rev, modules)
+		}
+		if err != nil {
+			// Restoring the snapshot when the new flows were not deployed (or were changed since)
+			// would revert any changes which were made in the meantime
+			if !rollback || nodered.NotApplied(err) || errors.Is(err, ErrFlowsChanged) {
+				return nil, err
+			}
+			slog.Error("Deployment failed, rolling back to the previous flows.", "err", err)
+			ctx, cancel := rollbackContext(client)
+			_, restoreErr := RestoreSnapshotContext(ctx, client, current)
+			cancel()
+			if restoreErr != nil {
+				return nil, errors.Join(err, fmt.Errorf("rollback failed. %w", restoreErr))
+			}
+			return nil, err
+		}
+		return &nodered.FlowDocument{Flows: flows, Rev: rev}, nil
+	}
+}
+
+// CheckDeployment checks that the deployed flows contain the given modules. An optional user defined
+// command can be used to perform additional checks. If the checks fail and the flows are no longer the
+// expected revision, then ErrFlowsChanged is returned, as the flows must not be rolled back
+func CheckDeployment(client *nodered.Client, rev string, modules []string) error {
+	ctx := client.Context()
+	if delay := viper.GetDuration("nodered.flows.rollback.check_delay"); delay > 0 {
+		slog.Info("Waiting before checking deployment.", "delay", delay)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w. %w", ErrPostDeployCheck, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+
+	doc, err := client.GetFlowDocument()
+	if err != nil {
+		return fmt.Errorf("%w. %w", ErrPostDeployCheck, err)
+	}
+	if err := checkDeployedFlows(ctx, doc, modules); err != nil {
+		if rev != "" && doc.Rev != rev {
+			// e.g. the flows were deployed from the editor in the meantime, and rolling back would revert them
+			return fmt.Errorf("%w. expected=%s, got=%s. %w", ErrFlowsChanged, rev, doc.Rev, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func checkDeployedFlows(ctx context.Context, doc *nodered.FlowDocument, modules []string) error {
+	for _, module := range modules {
+		if len(nodered.ModuleNodes(doc.Flows, module)) == 0 {
+			return fmt.Errorf("%w. module is missing. name=%s", ErrPostDeployCheck, module)
+		}
+	}
+
+	if command := viper.GetString("nodered.flows.rollback.check_command"); command != "" {
+		slog.Info("Running post deployment check.", "command", command)
+		output, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w. command=%s, output=%s. %w", ErrPostDeployCheck, command, output, err)
+		}
+	}
+	return nil
+}
+
+// rollbackCmd represents the rollback command
+func NewRollbackCommand(ctx cli.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback",
+		Short: "Restore the flows which were deployed before the last deployment",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			snapshot, err := LoadSnapshot()
+			if err != nil {
+				return fmt.Errorf("could not load flows snapshot. %w", err)
+			}
+
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			resp, err := RestoreSnapshot(client, snapshot)
+			if err != nil {
+				return err
+			}
+			slog.Info("New revision.", "rev", resp.Rev)
+			return nil
+		},
+	}
+}
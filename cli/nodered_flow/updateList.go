@@ -4,21 +4,123 @@ Copyright © 2024 thin-edge.io <info@thin-edge.io>
 package nodered_flow
 
 import (
+	"fmt"
 	"log/slog"
-	"os"
+	"slices"
 
 	"github.com/spf13/cobra"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
 )
 
 // updateListCmd represents the updateList command
 func NewUpdateListCommand(ctx cli.Cli) *cobra.Command {
 	return &cobra.Command{
-		Use:   "update-list",
-		Short: "Not implemented",
-		Run: func(cmd *cobra.Command, args []string) {
-			slog.Info("update-list is not supported")
-			os.Exit(1)
+		Use:   cli.UpdateListCommand,
+		Short: "Install and remove multiple flows in a single deployment",
+		Long: `Install and remove multiple flows in a single deployment.
+
+The list of actions is read from stdin, where each line is in the form of:
+	install	<MODULE_NAME>	<MODULE_VERSION>	<FILE>
+	remove	<MODULE_NAME>	<MODULE_VERSION>
+
+All of the artifacts are validated before anything is deployed, and the
+resulting flows are deployed to node-red in a single request.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			actions, err := cli.ParseUpdateList(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if len(actions) == 0 {
+				slog.Info("Nothing to update.")
+				return nil
+			}
+
+			// Read all artifacts before changing anything
+			modules := make(map[int]*Module)
+			for i, action := range actions {
+				if action.Action != cli.ActionInstall {
+					continue
+				}
+				module, err := ReadModule(action.Path, action.Name, action.Version)
+				if err != nil {
+					return fmt.Errorf("invalid artifact. name=%s, version=%s. %w", action.Name, action.Version, err)
+				}
+				modules[i] = module
+			}
+
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			current, err := client.GetFlowDocument()
+			if err != nil {
+				return err
+			}
+
+			mode := GetInstallMode()
+			installed := make([]string, 0)
+			pending := make(map[string]*Module)
+			encrypted := ""
+			build := func(flows []nodered.Node) ([]nodered.Node, error) {
+				installed = installed[:0]
+				clear(pending)
+				encrypted = ""
+				for i, action := range actions {
+					switch action.Action {
+					case cli.ActionInstall:
+						if err := CheckCredentialsMode(mode, modules[i].EncryptedCredentials); err != nil {
+							return nil, fmt.Errorf("%w. name=%s", err, action.Name)
+						}
+						if mode == InstallModeReplace {
+							installed = installed[:0]
+							encrypted = modules[i].EncryptedCredentials
+						}
+						installed = append(installed, action.Name)
+						pending[action.Name] = modules[i]
+						slog.Info("Installing module.", "name", action.Name, "version", action.Version)
+						next, err := InstallModule(mode, flows, action.Name, modules[i].Nodes)
+						if err != nil {
+							return nil, err
+						}
+						flows = next
+					case cli.ActionRemove:
+						owned := ModuleNodesToRemove(flows, action.Name, action.Version)
+						if len(owned) == 0 {
+							return nil, fmt.Errorf("%w. name=%s, version=%s", nodered.ErrModuleNotInstalled, action.Name, action.Version)
+						}
+						slog.Info("Removing module.", "name", action.Name, "version", action.Version)
+						flows = nodered.RemoveNodes(flows, owned)
+						installed = slices.DeleteFunc(installed, func(v string) bool { return v == action.Name })
+					}
+				}
+				return flows, nil
+			}
+			flows, err := build(current.Flows)
+			if err != nil {
+				return err
+			}
+
+			// Only check the modules which are still installed at the end of the batch
+			required := make([]*Module, 0, len(installed))
+			for _, name := range installed {
+				required = append(required, pending[name])
+			}
+			if err := CheckDependencies(client, required); err != nil {
+				return err
+			}
+
+			deployed, err := Deploy(client, current, flows, build, EncryptedCredentialsBody(encrypted), installed)
+			if err != nil {
+				return err
+			}
+			RecordDeployment(deployed.Flows, required)
+			slog.Info("New revision.", "rev", deployed.Rev, "actions", len(actions))
+			return nil
 		},
 	}
 }
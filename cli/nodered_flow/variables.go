@@ -0,0 +1,122 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_flow
+
+import (
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/tedge"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/variables"
+)
+
+func GetTedgeConfigPath() string {
+	v := viper.GetString("tedge.config_file")
+	if v == "" {
+		v = tedge.DefaultConfigPath
+	}
+	return v
+}
+
+// Built-in variables which are read from the thin-edge.io settings
+var tedgeVariables = sync.OnceValue(func() map[string]string {
+	settings, err := tedge.ReadSettings(GetTedgeConfigPath())
+	if err != nil {
+		slog.Warn("Could not read thin-edge.io settings.", "path", GetTedgeConfigPath(), "err", err)
+		return map[string]string{}
+	}
+	return settings.Variables()
+})
+
+func tedgeSettingSource(name string) (string, bool) {
+	v, ok := tedgeVariables()[name]
+	return v, ok
+}
+
+// GetConfiguredVariables returns the variables defined in the plugin configuration
+func GetConfiguredVariables() map[string]string {
+	configured := make(map[string]string)
+	// Note: viper keys are case-insensitive, so assume that variables use upper case
+	for key, value := range viper.GetStringMapString("nodered.flows.variables") {
+		configured[strings.ToUpper(key)] = value
+	}
+	return configured
+}
+
+// GetTemplatingAllowed returns the patterns of the variables which are replaced in flows (the built-in thin-edge.io variables by default)
+func GetTemplatingAllowed() []string {
+	viper.SetDefault("nodered.flows.templating.allowed", []string{"TEDGE_*"})
+	return viper.GetStringSlice("nodered.flows.templating.allowed")
+}
+
+// NewVariableResolver creates the resolver used to replace ${NAME} placeholders in flows before they are deployed.
+// Variables are looked up in the following order: plugin configuration, environment and thin-edge.io settings.
+// Only variables defined in the plugin configuration or matching the allowed patterns are replaced
+func NewVariableResolver() *variables.Resolver {
+	configured := GetConfiguredVariables()
+	allowed := GetTemplatingAllowed()
+	for name := range configured {
+		allowed = append(allowed, name)
+	}
+
+	return &variables.Resolver{
+		Sources: []variables.Source{
+			variables.MapSource(configured),
+			os.LookupEnv,
+			tedgeSettingSource,
+		},
+		Allowed: allowed,
+		Strict:  viper.GetBool("nodered.flows.templating.strict"),
+	}
+}
+
+// ResolveVariables replaces the variables in the flows. Unresolved variables are left as is,
+// as node-red can still resolve them from its own environment at runtime
+func ResolveVariables(b []byte) ([]byte, error) {
+	key := "nodered.flows.templating.enabled"
+	if viper.IsSet(key) && !viper.GetBool(key) {
+		return b, nil
+	}
+	out, unresolved, err := NewVariableResolver().Resolve(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(unresolved) > 0 {
+		slog.Warn("Flows contain unresolved variables. They will be resolved by node-red at runtime if set in its environment.", "names", unresolved)
+	}
+	return out, nil
+}
+
+// GetTedgeEnvEnabled checks if the built-in thin-edge.io variables should be added to the env of each installed tab (enabled by default)
+func GetTedgeEnvEnabled() bool {
+	viper.SetDefault("nodered.flows.tedge_env", true)
+	return viper.GetBool("nodered.flows.tedge_env")
+}
+
+// TedgeEnv returns the built-in thin-edge.io variables which are added to the env of each installed tab.
+// Values defined in the plugin configuration take precedence over the thin-edge.io settings
+func TedgeEnv() []nodered.FlowEnv {
+	configured := GetConfiguredVariables()
+	builtins := tedgeVariables()
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := make([]nodered.FlowEnv, 0, len(names))
+	for _, name := range names {
+		value := builtins[name]
+		if v, ok := configured[name]; ok {
+			value = v
+		}
+		env = append(env, nodered.FlowEnv{Name: name, Value: value, Type: "str"})
+	}
+	return env
+}
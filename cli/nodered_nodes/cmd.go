@@ -0,0 +1,33 @@
+package nodered_nodes
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+// NewCommand returns a cobra command for `nodered-nodes` subcommands
+func NewCommand(cmdCli cli.Cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nodered-nodes",
+		Short: "thin-edge.io software management plugin to manage nodered palette modules",
+	}
+	cmd.AddCommand(
+		NewPrepareCommand(cmdCli),
+		NewInstallCommand(cmdCli),
+		NewRemoveCommand(cmdCli),
+		NewUpdateListCommand(cmdCli),
+		NewListCommand(cmdCli),
+		NewFinalizeCommand(cmdCli),
+		cli.NewDriftCommand(SoftwareType, func(ctx context.Context) ([]registry.LiveModule, error) {
+			client, err := cli.NewClientWithRetries(ctx, cli.GetAPI())
+			if err != nil {
+				return nil, err
+			}
+			return LiveModules(client)
+		}),
+	)
+	return cmd
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/utils"
+)
+
+var ErrPackageNameMismatch = errors.New("package name of the tarball does not match the module name")
+
+type InstallCommand struct {
+	*cobra.Command
+
+	CommandContext cli.Cli
+	ModuleVersion  string
+	File           string
+}
+
+// installCmd represents the install command
+func NewInstallCommand(ctx cli.Cli) *cobra.Command {
+	command := &InstallCommand{
+		CommandContext: ctx,
+	}
+	cmd := &cobra.Command{
+		Use:   "install <MODULE_NAME>",
+		Short: "Install a palette module",
+		Long: `Install a palette module.
+
+If a file is given, then it must be a tarball of the module (e.g. created by npm pack),
+where the package name must match the module name, otherwise the module is installed from the npm registry which is configured in node-red.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: command.RunE,
+	}
+
+	cmd.Flags().StringVar(&command.ModuleVersion, "module-version", "", "Software version to install")
+	cmd.Flags().StringVar(&command.File, "file", "", "File")
+	command.Command = cmd
+	return cmd
+}
+
+func (c *InstallCommand) RunE(cmd *cobra.Command, args []string) error {
+	slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+	client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+	if err != nil {
+		return err
+	}
+	return InstallModule(client, args[0], c.ModuleVersion, c.File)
+}
+
+// IsTarball checks if the file is a gzip compressed tarball
+func IsTarball(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(header, []byte{0x1f, 0x8b}), nil
+}
+
+// CheckTarball checks that the file is a tarball of the given module. node-red installs the module
+// using the name of the package, so it must match the module name, otherwise the installed module
+// could not be listed or removed using the module name
+func CheckTarball(path string, name string) error {
+	ok, err := IsTarball(path)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("file is not a tarball. path=%s", path)
+	}
+	files, err := utils.ReadArchiveFiles(path, "package.json")
+	if err != nil {
+		return err
+	}
+	b, ok := files["package.json"]
+	if !ok {
+		return fmt.Errorf("tarball does not contain a package.json file. path=%s", path)
+	}
+	pkg := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return fmt.Errorf("invalid package.json. path=%s. %w", path, err)
+	}
+	if pkg.Name != name {
+		return fmt.Errorf("%w. name=%s, package=%s", ErrPackageNameMismatch, name, pkg.Name)
+	}
+	return nil
+}
+
+// InstallModule installs a module either from a tarball or from the npm registry
+func InstallModule(client *nodered.Client, name string, version string, path string) error {
+	if path != "" {
+		if err := CheckTarball(path, name); err != nil {
+			return err
+		}
+		slog.Info("Installing module from tarball.", "name", name, "version", version, "path", path)
+		module, err := client.InstallNodeModuleFromFile(path)
+		if err != nil {
+			return err
+		}
+		RecordModule(module, path)
+		slog.Info("Installed module.", "name", module.Name, "version", module.Version)
+		return nil
+	}
+
+	modules, err := client.GetNodeModules()
+	if err != nil {
+		return err
+	}
+	for _, module := range modules {
+		if module.Name == name && (version == "" || module.Version == version) {
+			slog.Info("Module is already installed.", "name", name, "version", module.Version)
+			RecordModule(&module, "")
+			return nil
+		}
+	}
+
+	// node-red updates the module if a different version is already installed
+	slog.Info("Installing module from registry.", "name", name, "version", version)
+	module, err := client.InstallNodeModule(name, version)
+	if err != nil {
+		return err
+	}
+	RecordModule(module, "")
+	slog.Info("Installed module.", "name", module.Name, "version", module.Version)
+	return nil
+}
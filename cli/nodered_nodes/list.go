@@ -0,0 +1,41 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_nodes
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+)
+
+// listCmd represents the list command
+func NewListCommand(cliContext cli.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List installed nodered palette modules",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			client, err := cli.NewClientWithoutRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			modules, err := LiveModules(client)
+			if err != nil {
+				// Don't fail the API is not ready yet
+				slog.Warn("nodered api is not yet available.", "err", err)
+				return nil
+			}
+			cli.ReconcileRegistry(SoftwareType, modules)
+
+			for _, module := range modules {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", module.Name, module.Version)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_nodes
+
+import (
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+)
+
+// prepareCmd represents the prepare command
+func NewPrepareCommand(ctx cli.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "prepare",
+		Short: "Prepare for install/removal",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			// Check if the palette can be managed
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			if err := cli.WaitForReady(client); err != nil {
+				return err
+			}
+			_, err = client.GetNodes()
+			return err
+		},
+	}
+}
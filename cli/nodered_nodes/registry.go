@@ -0,0 +1,50 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_nodes
+
+import (
+	"log/slog"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+// SoftwareType is the software type used to record the palette modules in the registry
+const SoftwareType = "nodered-nodes"
+
+// LiveModules returns the installed palette modules
+func LiveModules(client *nodered.Client) ([]registry.LiveModule, error) {
+	modules, err := client.GetNodeModules()
+	if err != nil {
+		return nil, err
+	}
+	live := make([]registry.LiveModule, 0, len(modules))
+	for _, module := range modules {
+		live = append(live, registry.LiveModule{
+			Name:    module.Name,
+			Version: module.Version,
+		})
+	}
+	return live, nil
+}
+
+// RecordModule records an installed module in the registry
+func RecordModule(module *nodered.NodeModule, path string) {
+	checksum, err := registry.Checksum(path)
+	if err != nil {
+		slog.Warn("Could not calculate checksum.", "path", path, "err", err)
+	}
+	cli.UpdateRegistry(func(r *registry.Registry) {
+		if existing, ok := r.Get(SoftwareType, module.Name); ok && existing.Version == module.Version && checksum == "" {
+			// The module was not changed
+			return
+		}
+		r.Put(SoftwareType, &registry.Module{
+			Name:     module.Name,
+			Version:  module.Version,
+			Checksum: checksum,
+		})
+	})
+}
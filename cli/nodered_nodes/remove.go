@@ -0,0 +1,67 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_nodes
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+type RemoveCommand struct {
+	*cobra.Command
+
+	ModuleVersion string
+}
+
+// removeCmd represents the remove command
+func NewRemoveCommand(ctx cli.Cli) *cobra.Command {
+	command := &RemoveCommand{}
+	cmd := &cobra.Command{
+		Use:   "remove <MODULE_NAME>",
+		Short: "Remove a palette module",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			return RemoveModule(client, args[0])
+		},
+	}
+	cmd.Flags().StringVar(&command.ModuleVersion, "module-version", "", "Software version to remove")
+	return cmd
+}
+
+// RemoveModule removes an installed module
+func RemoveModule(client *nodered.Client, name string) error {
+	if name == nodered.CoreModule {
+		return fmt.Errorf("can not remove the core module. name=%s", name)
+	}
+	modules, err := client.GetNodeModules()
+	if err != nil {
+		return err
+	}
+	installed := slices.ContainsFunc(modules, func(m nodered.NodeModule) bool {
+		return m.Name == name
+	})
+	if !installed {
+		return fmt.Errorf("%w. name=%s", nodered.ErrModuleNotInstalled, name)
+	}
+	if err := client.RemoveNodeModule(name); err != nil {
+		return err
+	}
+	cli.UpdateRegistry(func(r *registry.Registry) {
+		r.Delete(SoftwareType, name)
+	})
+	slog.Info("Uninstalled module.", "name", name)
+	return nil
+}
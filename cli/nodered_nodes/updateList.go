@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_nodes
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+)
+
+// updateListCmd represents the updateList command
+func NewUpdateListCommand(ctx cli.Cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   cli.UpdateListCommand,
+		Short: "Install and remove multiple palette modules",
+		Long: `Install and remove multiple palette modules.
+
+The list of actions is read from stdin, where each line is in the form of:
+	install	<MODULE_NAME>	<MODULE_VERSION>	<FILE>
+	remove	<MODULE_NAME>	<MODULE_VERSION>
+
+The actions are applied in order.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			actions, err := cli.ParseUpdateList(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if len(actions) == 0 {
+				slog.Info("Nothing to update.")
+				return nil
+			}
+
+			// Check all artifacts before changing anything
+			for _, action := range actions {
+				if action.Action != cli.ActionInstall || action.Path == "" {
+					continue
+				}
+				if err := CheckTarball(action.Path, action.Name); err != nil {
+					return fmt.Errorf("invalid artifact. name=%s, version=%s. %w", action.Name, action.Version, err)
+				}
+			}
+
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			for _, action := range actions {
+				switch action.Action {
+				case cli.ActionInstall:
+					err = InstallModule(client, action.Name, action.Version, action.Path)
+				case cli.ActionRemove:
+					err = RemoveModule(client, action.Name)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_project
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+var commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+// IsCommit checks if the ref looks like a commit sha rather than a branch or tag
+func IsCommit(ref string) bool {
+	return commitPattern.MatchString(ref)
+}
+
+// GetRef returns the tag or commit which the project is pinned to. The ref takes precedence
+// over the tag format, and an empty value is returned if the project is not pinned
+func (p *ProjectDescription) GetRef(version string) string {
+	if p.Ref != "" {
+		return p.Ref
+	}
+	if p.TagFormat != "" && version != "" && version != "latest" {
+		return strings.ReplaceAll(p.TagFormat, "{version}", version)
+	}
+	return ""
+}
+
+// GetCloneBranch returns the branch or tag which can be checked out when cloning the project
+func (p *ProjectDescription) GetCloneBranch(version string) string {
+	if ref := p.GetRef(version); ref != "" {
+		if IsCommit(ref) {
+			return ""
+		}
+		return ref
+	}
+	return p.Branch
+}
+
+// CheckoutProject checks out the pinned commit of a newly cloned and activated project,
+// as commits can't be checked out when cloning
+func CheckoutProject(client *nodered.Client, name string, project *ProjectDescription, version string) error {
+	ref := project.GetRef(version)
+	if ref == "" || !IsCommit(ref) {
+		return nil
+	}
+	slog.Info("Checking out commit.", "name", name, "ref", ref)
+	_, err := client.ProjectCheckout(name, ref)
+	return err
+}
+
+// GitHead is the checked out commit of a project
+type GitHead struct {
+	Sha  string
+	Tags []string
+}
+
+// Version returns the tag (or the short sha if it is not tagged) of the commit
+func (h *GitHead) Version() string {
+	if len(h.Tags) > 0 {
+		return h.Tags[0]
+	}
+	if len(h.Sha) > 8 {
+		return h.Sha[0:8]
+	}
+	return h.Sha
+}
+
+// IsOn checks if the commit is the given tag or commit
+func (h *GitHead) IsOn(ref string) bool {
+	if ref == "" {
+		return false
+	}
+	if slices.Contains(h.Tags, ref) {
+		return true
+	}
+	return IsCommit(ref) && strings.HasPrefix(strings.ToLower(h.Sha), strings.ToLower(ref))
+}
+
+// PinnedVersion returns the installed version if the commit is the ref which the version was
+// pinned to, e.g. 1.0.0 rather than the tag v1.0.0. Otherwise the tag (or short sha) of the commit is returned
+func (h *GitHead) PinnedVersion(installed *registry.Module) string {
+	if installed != nil && h.IsOn(installed.Ref) {
+		return installed.Version
+	}
+	return h.Version()
+}
+
+// GetDeployedHead returns the checked out commit of the active project, or nil if the project has no commits
+func GetDeployedHead(client *nodered.Client, name string) (*GitHead, error) {
+	commits, err := client.ProjectCommits(name, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits.Commits) == 0 {
+		return nil, nil
+	}
+	commit := commits.Commits[0]
+	return &GitHead{Sha: commit.Sha, Tags: commit.Tags()}, nil
+}
+
+// GetActiveVersion returns the version of the active project using the configured version source.
+// The installed module (if any) is used to report its version when the project is on the pinned ref
+func GetActiveVersion(client *nodered.Client, name string, installed *registry.Module) (string, error) {
+	switch source := GetVersionSource(); source {
+	case VersionSourceGit:
+		head, err := GetDeployedHead(client, name)
+		if err != nil || head == nil {
+			return "", err
+		}
+		return head.PinnedVersion(installed), nil
+	case VersionSourcePackage:
+		project, err := client.ProjectGet(name)
+		if err != nil {
+			return "", err
+		}
+		return project.Version, nil
+	default:
+		return "", fmt.Errorf("invalid version source. source=%s", source)
+	}
+}
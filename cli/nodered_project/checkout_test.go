@@ -0,0 +1,53 @@
+package nodered_project
+
+import (
+	"testing"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+func TestPinnedVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		head      GitHead
+		installed *registry.Module
+		want      string
+	}{
+		{
+			name:      "tag of the installed version",
+			head:      GitHead{Sha: "abcdef1234567890", Tags: []string{"v1.0.0"}},
+			installed: &registry.Module{Version: "1.0.0", Ref: "v1.0.0"},
+			want:      "1.0.0",
+		},
+		{
+			name:      "commit of the installed version",
+			head:      GitHead{Sha: "abcdef1234567890"},
+			installed: &registry.Module{Version: "1.0.0", Ref: "ABCDEF1"},
+			want:      "1.0.0",
+		},
+		{
+			name:      "moved to another tag",
+			head:      GitHead{Sha: "abcdef1234567890", Tags: []string{"v1.1.0"}},
+			installed: &registry.Module{Version: "1.0.0", Ref: "v1.0.0"},
+			want:      "v1.1.0",
+		},
+		{
+			name:      "installed version is not pinned",
+			head:      GitHead{Sha: "abcdef1234567890", Tags: []string{"v1.0.0"}},
+			installed: &registry.Module{Version: "latest"},
+			want:      "v1.0.0",
+		},
+		{
+			name: "not installed by the plugin",
+			head: GitHead{Sha: "abcdef1234567890"},
+			want: "abcdef12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.head.PinnedVersion(tt.installed); got != tt.want {
+				t.Errorf("PinnedVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
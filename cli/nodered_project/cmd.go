@@ -1,19 +1,36 @@
 package nodered_project
 
 import (
+	"context"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+const (
+	// VersionSourcePackage reports the version of the project's package.json
+	VersionSourcePackage = "package"
+	// VersionSourceGit reports the tag (or short sha) of the checked out commit
+	VersionSourceGit = "git"
 )
 
-func GetAPI() string {
-	v := viper.GetString("nodered.api")
+// GetVersionSource returns where the version of the active project is read from.
+// The deployed commit is reported by default, so that the version shows what is actually running
+func GetVersionSource() string {
+	v := viper.GetString("nodered.projects.version_source")
 	if v == "" {
-		v = "http://127.0.0.1:1880"
+		v = VersionSourceGit
 	}
 	return v
 }
 
+// GetForceEnabled checks if local changes of existing projects should be discarded when installing
+func GetForceEnabled() bool {
+	return viper.GetBool("nodered.projects.force")
+}
+
 // NewCommand returns a cobra command for `nodered_project` subcommands
 func NewCommand(cmdCli cli.Cli) *cobra.Command {
 	cmd := &cobra.Command{
@@ -27,6 +44,13 @@ func NewCommand(cmdCli cli.Cli) *cobra.Command {
 		NewUpdateListCommand(cmdCli),
 		NewListCommand(cmdCli),
 		NewFinalizeCommand(cmdCli),
+		cli.NewDriftCommand(SoftwareType, func(ctx context.Context) ([]registry.LiveModule, error) {
+			client, err := cli.NewClientWithRetries(ctx, cli.GetAPI())
+			if err != nil {
+				return nil, err
+			}
+			return LiveModules(client)
+		}),
 	)
 	return cmd
 }
@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_project
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+var ErrCredentialsNotFound = errors.New("credentials are not configured")
+
+// DefaultTokenUsername is the username used when authenticating with an access token.
+// Most git servers accept any username along with the token
+const DefaultTokenUsername = "git"
+
+// RepositoryCredentials are the device local credentials of a git repository
+type RepositoryCredentials struct {
+	Token    string
+	Username string
+	Password string
+	SSHKey   string
+	// Passphrase of the ssh key
+	Passphrase string
+	// Secret used by node-red to encrypt the credentials of the project's flows
+	CredentialSecret string
+}
+
+// IsEmpty checks if any credentials are used to access the repository
+func (c *RepositoryCredentials) IsEmpty() bool {
+	return c.Token == "" && c.Username == "" && c.Password == "" && c.SSHKey == ""
+}
+
+// Remote returns the remote repository including the credentials
+func (c *RepositoryCredentials) Remote(url string) nodered.Repository {
+	remote := nodered.Repository{
+		URL: url,
+	}
+	switch {
+	case c.SSHKey != "":
+		remote.KeyFile = c.SSHKey
+		remote.Passphrase = c.Passphrase
+	case c.Token != "":
+		remote.Username = c.Username
+		if remote.Username == "" {
+			remote.Username = DefaultTokenUsername
+		}
+		remote.Password = c.Token
+	default:
+		remote.Username = c.Username
+		remote.Password = c.Password
+	}
+	return remote
+}
+
+// GetRepositoryCredentials reads the credentials from the plugin configuration, e.g.
+//
+//	[nodered.projects.credentials.<name>]
+//	token = "..."
+func GetRepositoryCredentials(name string) (*RepositoryCredentials, error) {
+	key := "nodered.projects.credentials." + strings.ToLower(name)
+	if !viper.IsSet(key) {
+		return nil, fmt.Errorf("%w. name=%s", ErrCredentialsNotFound, name)
+	}
+	return &RepositoryCredentials{
+		Token:            viper.GetString(key + ".token"),
+		Username:         viper.GetString(key + ".username"),
+		Password:         viper.GetString(key + ".password"),
+		SSHKey:           viper.GetString(key + ".ssh_key"),
+		Passphrase:       viper.GetString(key + ".passphrase"),
+		CredentialSecret: viper.GetString(key + ".credential_secret"),
+	}, nil
+}
+
+// GetCredentials returns the credentials referenced by the project. If the project does not
+// reference any credentials, then empty credentials are returned
+func (p *ProjectDescription) GetCredentials() (*RepositoryCredentials, error) {
+	if p.Credentials == "" {
+		return &RepositoryCredentials{}, nil
+	}
+	return GetRepositoryCredentials(p.Credentials)
+}
+
+// CloneProject clones the project using the referenced credentials. The requested branch or tag is
+// checked out by the clone, however commits can only be checked out once the project is active
+func CloneProject(client *nodered.Client, name string, project *ProjectDescription, version string) error {
+	credentials, err := project.GetCredentials()
+	if err != nil {
+		return err
+	}
+	remote := credentials.Remote(project.Repository)
+	remote.Branch = project.GetCloneBranch(version)
+	_, err = client.ProjectClone(name, remote, credentials.CredentialSecret)
+	return err
+}
+
+// SetRemoteCredentials sets the referenced credentials of the active project before accessing
+// the remote, as node-red does not keep them after being restarted
+func SetRemoteCredentials(client *nodered.Client, name string, project *ProjectDescription) error {
+	credentials, err := project.GetCredentials()
+	if err != nil {
+		return err
+	}
+	if credentials.IsEmpty() {
+		return nil
+	}
+	slog.Info("Setting repository credentials.", "name", name, "credentials", project.Credentials)
+	return client.ProjectSetRemoteCredentials(name, credentials.Remote(""))
+}
@@ -5,13 +5,13 @@ package nodered_project
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
-	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
 )
 
 type InstallCommand struct {
@@ -20,10 +20,24 @@ type InstallCommand struct {
 	CommandContext cli.Cli
 	ModuleVersion  string
 	File           string
+	Force          bool
 }
 
 type ProjectDescription struct {
 	Repository string `json:"repo,omitempty"`
+
+	// Name of the device local credentials used to access the repository.
+	// The credentials themselves are never included in the artifact
+	Credentials string `json:"credentials,omitempty"`
+
+	// Branch to check out (default is the remote's default branch)
+	Branch string `json:"branch,omitempty"`
+
+	// Tag or commit to check out. This takes precedence over the branch
+	Ref string `json:"ref,omitempty"`
+
+	// Format used to map the module version to a tag, e.g. "v{version}"
+	TagFormat string `json:"tag_format,omitempty"`
 }
 
 // installCmd represents the install command
@@ -40,63 +54,63 @@ func NewInstallCommand(ctx cli.Cli) *cobra.Command {
 
 	cmd.Flags().StringVar(&command.ModuleVersion, "module-version", "", "Software version to install")
 	cmd.Flags().StringVar(&command.File, "file", "", "File")
+	cmd.Flags().BoolVar(&command.Force, "force", false, "Discard local changes of an existing project")
 	command.Command = cmd
 	return cmd
 }
 
 func (c *InstallCommand) RunE(cmd *cobra.Command, args []string) error {
 	slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
-	client := nodered.NewClientWithRetries(GetAPI())
-
-	file, err := os.Open(c.File)
+	client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	b, err := io.ReadAll(file)
+	project, err := ReadProjectDescription(c.File)
 	if err != nil {
 		return err
 	}
 
-	project := &ProjectDescription{}
-	err = json.Unmarshal(b, &project)
-	if err != nil {
+	projectName := args[0]
+	installer := &Installer{
+		Client:  client,
+		Name:    projectName,
+		Project: project,
+		Version: c.ModuleVersion,
+		Force:   c.Force || GetForceEnabled(),
+	}
+	if err := installer.Run(); err != nil {
 		return err
 	}
+	RecordActiveProject(client, projectName, c.ModuleVersion, c.File)
+
+	slog.Info("Installed module.", "name", projectName, "url", project.Repository)
+	return nil
+}
 
-	projects, err := client.ProjectList()
+// ReadProjectDescription reads the project deployment artifact
+func ReadProjectDescription(path string) (*ProjectDescription, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return err
-	}
-	projectName := args[0]
-	exists := false
-	for _, project := range projects.Projects {
-		if project == projectName {
-			exists = true
-			break
-		}
+		return nil, err
 	}
+	defer file.Close()
 
-	if exists {
-		slog.Info("Updating existing project.", "name", projectName)
-		if _, err := client.ProjectSetActive(projectName, true); err != nil {
-			return err
-		}
-		if _, err := client.ProjectPull(projectName); err != nil {
-			return err
-		}
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
 	}
 
-	slog.Info("Cloning new project.", "name", projectName)
-	if _, err := client.ProjectClone(projectName, project.Repository); err != nil {
-		return err
+	project := &ProjectDescription{}
+	err = json.Unmarshal(b, &project)
+	if err != nil {
+		return nil, err
 	}
-	slog.Info("Activating project.", "name", projectName)
-	if _, err := client.ProjectSetActive(projectName, true); err != nil {
-		return err
+	if project.Repository == "" {
+		return nil, fmt.Errorf("invalid project file. repo is empty. path=%s", path)
 	}
-
-	slog.Info("Installed module.", "name", projectName, "url", project.Repository)
-	return nil
+	if _, err := project.GetCredentials(); err != nil {
+		return nil, err
+	}
+	return project, nil
 }
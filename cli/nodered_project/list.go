@@ -6,23 +6,34 @@ package nodered_project
 import (
 	"fmt"
 	"log/slog"
-	"sort"
 
 	"github.com/spf13/cobra"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
-	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
 )
 
 // listCmd represents the list command
 func NewListCommand(cliContext cli.Cli) *cobra.Command {
-	return &cobra.Command{
+	showStatus := false
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List nodered projects",
-		Args:  cobra.ExactArgs(0),
+		Long: `List nodered projects.
+
+node-red only supports reading the version of the active project, so the version of
+inactive projects is read from the node-red user directory (nodered.user_dir), or
+from the version recorded when the project was installed.
+
+Each line is in the form of (the status is only included when using --status):
+	<MODULE_NAME>	<MODULE_VERSION>	<active|inactive>
+`,
+		Args: cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
 
-			client := nodered.NewClientWithoutRetries(GetAPI())
+			client, err := cli.NewClientWithoutRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
 			resp, err := client.ProjectList()
 			if err != nil {
 				// Don't fail the API is not ready yet
@@ -30,21 +41,33 @@ func NewListCommand(cliContext cli.Cli) *cobra.Command {
 				return nil
 			}
 
-			sort.Strings(resp.Projects)
+			live, err := liveProjects(client, resp)
+			if err != nil {
+				return err
+			}
+			installed, _ := cli.ReconcileRegistry(SoftwareType, live)
 
-			for _, name := range resp.Projects {
-				// nodered only supports getting info for the active project
-				if resp.Active == name {
-					project, err := client.ProjectGet(name)
-					if err != nil {
-						return err
+			for _, module := range live {
+				version := module.Version
+				if version == "" {
+					// Use the version recorded when the project was installed
+					if entry, ok := installed.Get(SoftwareType, module.Name); ok {
+						version = entry.Version
+					}
+				}
+				if showStatus {
+					status := "inactive"
+					if module.Name == resp.Active {
+						status = "active"
 					}
-					fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", name, project.Version)
+					fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\n", module.Name, version, status)
 				} else {
-					fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", name, "inactive")
+					fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", module.Name, version)
 				}
 			}
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&showStatus, "status", false, "Include whether the project is active or inactive")
+	return cmd
 }
@@ -8,7 +8,6 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
-	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
 )
 
 // prepareCmd represents the prepare command
@@ -20,8 +19,14 @@ func NewPrepareCommand(ctx cli.Cli) *cobra.Command {
 			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
 
 			// Check if the node-red project mode is enabled
-			client := nodered.NewClientWithRetries(GetAPI())
-			_, err := client.ProjectList()
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			if err := cli.WaitForReady(client); err != nil {
+				return err
+			}
+			_, err = client.ProjectList()
 			return err
 		},
 	}
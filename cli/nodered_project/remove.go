@@ -4,13 +4,19 @@ Copyright © 2024 thin-edge.io <info@thin-edge.io>
 package nodered_project
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
 )
 
+var ErrActiveProject = errors.New("active project could not be changed")
+
 type RemoveCommand struct {
 	*cobra.Command
 
@@ -21,23 +27,114 @@ type RemoveCommand struct {
 func NewRemoveCommand(ctx cli.Cli) *cobra.Command {
 	command := &RemoveCommand{}
 	cmd := &cobra.Command{
-		Use:   "remove",
+		Use:   "remove <MODULE_NAME>",
 		Short: "Remove project",
-		Args:  cobra.ExactArgs(1),
+		Long: `Remove project.
+
+If the project is active, then the configured fallback project is activated,
+otherwise the project is deactivated before it is removed.
+`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
 			projectName := args[0]
 
-			client := nodered.NewClientWithRetries(GetAPI())
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+
+			projects, err := client.ProjectList()
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(projects.Projects, projectName) {
+				return fmt.Errorf("%w. name=%s", nodered.ErrModuleNotInstalled, projectName)
+			}
+			if projects.Active == projectName {
+				if err := ReleaseActiveProject(client, projectName); err != nil {
+					return err
+				}
+			}
 
-			// Note: This will fail if the current project is active
 			if err := client.ProjectDelete(projectName); err != nil {
 				return err
 			}
+			ForgetProject(projectName)
 			slog.Info("Uninstalled project.", "name", projectName)
-			return nil
+			return ReportActiveProject(client)
 		},
 	}
 	cmd.Flags().StringVar(&command.ModuleVersion, "module-version", "", "Software version to remove")
 	return cmd
 }
+
+// GetFallbackProject returns the project which is activated when the active project is removed
+func GetFallbackProject() (string, *ProjectDescription) {
+	name := viper.GetString("nodered.projects.fallback.name")
+	if name == "" {
+		return "", nil
+	}
+	return name, &ProjectDescription{
+		Repository:  viper.GetString("nodered.projects.fallback.repo"),
+		Credentials: viper.GetString("nodered.projects.fallback.credentials"),
+		Branch:      viper.GetString("nodered.projects.fallback.branch"),
+	}
+}
+
+// ReleaseActiveProject makes sure that the project is no longer active, so that it can be deleted.
+// The fallback project is activated (and cloned if required), otherwise the project is deactivated.
+// The project is left active if this fails, so that node-red keeps running the current project
+func ReleaseActiveProject(client *nodered.Client, name string) error {
+	fallbackName, fallback := GetFallbackProject()
+	if fallback != nil {
+		if fallbackName == name {
+			return fmt.Errorf("%w. the fallback project can not be removed while it is active. name=%s", ErrActiveProject, name)
+		}
+		projects, err := client.ProjectList()
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(projects.Projects, fallbackName) {
+			if fallback.Repository == "" {
+				return fmt.Errorf("%w. fallback project does not exist and no repo is configured. fallback=%s", ErrActiveProject, fallbackName)
+			}
+			slog.Info("Cloning fallback project.", "name", fallbackName)
+			if err := CloneProject(client, fallbackName, fallback, ""); err != nil {
+				return fmt.Errorf("%w. could not clone fallback project. fallback=%s. %w", ErrActiveProject, fallbackName, err)
+			}
+		}
+		slog.Info("Activating fallback project.", "name", fallbackName, "previous", name)
+		if _, err := client.ProjectSetActive(fallbackName, true); err != nil {
+			return fmt.Errorf("%w. could not activate fallback project. fallback=%s. %w", ErrActiveProject, fallbackName, err)
+		}
+	} else {
+		slog.Info("Deactivating project.", "name", name)
+		if err := client.ProjectDeactivate(name); err != nil {
+			return fmt.Errorf("%w. could not deactivate the project, configure a fallback project instead. name=%s. %w", ErrActiveProject, name, err)
+		}
+	}
+
+	projects, err := client.ProjectList()
+	if err != nil {
+		return err
+	}
+	if projects.Active == name {
+		return fmt.Errorf("%w. project is still active. name=%s", ErrActiveProject, name)
+	}
+	return nil
+}
+
+// ReportActiveProject logs the project which is active
+func ReportActiveProject(client *nodered.Client) error {
+	projects, err := client.ProjectList()
+	if err != nil {
+		return err
+	}
+	if projects.Active == "" {
+		slog.Info("No project is active.")
+		return nil
+	}
+	slog.Info("Active project.", "name", projects.Active)
+	return nil
+}
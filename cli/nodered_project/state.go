@@ -0,0 +1,226 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_project
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+var (
+	ErrLocalChanges    = errors.New("project has local changes. use --force to discard them")
+	ErrDivergedHistory = errors.New("project history has diverged from the remote. remove and install the project again")
+	ErrMergeInProgress = errors.New("project has a merge in progress. use --force to abort it")
+	ErrNoTrackedBranch = errors.New("project is not on a branch which tracks a remote branch. set the branch of the project to update it")
+)
+
+type installState string
+
+const (
+	stateDetect   installState = "detect"
+	stateClone    installState = "clone"
+	stateActivate installState = "activate"
+	stateFetch    installState = "fetch"
+	stateStatus   installState = "status"
+	stateDiscard  installState = "discard"
+	stateCheckout installState = "checkout"
+	statePull     installState = "pull"
+	stateTrack    installState = "track"
+	stateDone     installState = "done"
+)
+
+// Installer installs a project, where each step of the installation is a separate state.
+// node-red only supports fetching, pulling and checking out the active project, so an existing
+// project is activated before it is updated
+type Installer struct {
+	Client  *nodered.Client
+	Name    string
+	Project *ProjectDescription
+	Version string
+
+	// Discard local changes of an existing project
+	Force bool
+
+	cloned           bool
+	branchCheckedOut bool
+	status           *nodered.ProjectStatus
+}
+
+func (i *Installer) Run() error {
+	state := stateDetect
+	for state != stateDone {
+		slog.Debug("Project install state.", "name", i.Name, "state", state)
+		next, err := i.step(state)
+		if err != nil {
+			return fmt.Errorf("project install failed. name=%s, state=%s. %w", i.Name, state, err)
+		}
+		state = next
+	}
+	return nil
+}
+
+func (i *Installer) step(state installState) (installState, error) {
+	switch state {
+	case stateDetect:
+		projects, err := i.Client.ProjectList()
+		if err != nil {
+			return "", err
+		}
+		if !slices.Contains(projects.Projects, i.Name) {
+			return stateClone, nil
+		}
+		if projects.Active == i.Name {
+			return stateFetch, nil
+		}
+		return stateActivate, nil
+
+	case stateClone:
+		slog.Info("Cloning new project.", "name", i.Name)
+		if err := CloneProject(i.Client, i.Name, i.Project, i.Version); err != nil {
+			return "", err
+		}
+		i.cloned = true
+		return stateActivate, nil
+
+	case stateActivate:
+		if i.cloned {
+			// node-red activates a project when it is cloned
+			projects, err := i.Client.ProjectList()
+			if err != nil {
+				return "", err
+			}
+			if projects.Active == i.Name {
+				return stateCheckout, nil
+			}
+		}
+		slog.Info("Activating project.", "name", i.Name)
+		if _, err := i.Client.ProjectSetActive(i.Name, true); err != nil {
+			return "", err
+		}
+		if i.cloned {
+			// The requested branch or tag is checked out by the clone
+			return stateCheckout, nil
+		}
+		return stateFetch, nil
+
+	case stateFetch:
+		slog.Info("Fetching project.", "name", i.Name)
+		if err := SetRemoteCredentials(i.Client, i.Name, i.Project); err != nil {
+			return "", err
+		}
+		status, err := i.Client.ProjectFetch(i.Name)
+		if err != nil {
+			return "", err
+		}
+		i.status = status
+		return stateStatus, nil
+
+	case stateStatus:
+		changed := i.status.ChangedFiles()
+		if i.status.Merging || len(changed) > 0 {
+			if !i.Force {
+				if i.status.Merging {
+					return "", ErrMergeInProgress
+				}
+				return "", fmt.Errorf("%w. files=%s", ErrLocalChanges, strings.Join(changed, ","))
+			}
+			return stateDiscard, nil
+		}
+		return stateCheckout, nil
+
+	case stateDiscard:
+		if i.status.Merging {
+			slog.Warn("Aborting merge.", "name", i.Name)
+			if err := i.Client.ProjectAbortMerge(i.Name); err != nil {
+				return "", err
+			}
+		}
+		changed := i.status.ChangedFiles()
+		if len(changed) > 0 {
+			slog.Warn("Discarding local changes.", "name", i.Name, "files", strings.Join(changed, ","))
+			if err := i.Client.ProjectUnstageAll(i.Name); err != nil {
+				return "", err
+			}
+			if err := i.Client.ProjectDiscard(i.Name, changed); err != nil {
+				return "", err
+			}
+		}
+		return stateCheckout, nil
+
+	case stateCheckout:
+		ref := i.Project.GetRef(i.Version)
+		if i.cloned {
+			// Only commits need to be checked out after cloning
+			if err := CheckoutProject(i.Client, i.Name, i.Project, i.Version); err != nil {
+				return "", err
+			}
+			return stateDone, nil
+		}
+		if ref != "" {
+			// Pinned projects are not pulled
+			slog.Info("Checking out ref.", "name", i.Name, "ref", ref)
+			_, err := i.Client.ProjectCheckout(i.Name, ref)
+			return stateDone, err
+		}
+		if i.Project.Branch != "" && i.Project.Branch != i.status.LocalBranch() && !i.branchCheckedOut {
+			slog.Info("Checking out branch.", "name", i.Name, "branch", i.Project.Branch)
+			if _, err := i.Client.ProjectCheckout(i.Name, i.Project.Branch); err != nil {
+				return "", err
+			}
+			i.branchCheckedOut = true
+			// The ahead/behind status belongs to the previous branch
+			return stateFetch, nil
+		}
+		return statePull, nil
+
+	case statePull:
+		commits := i.status.Commits
+		switch {
+		case i.status.RemoteBranch() == "":
+			// e.g. a detached HEAD after the project was pinned to a tag
+			return stateTrack, nil
+		case commits.Ahead > 0 && commits.Behind > 0:
+			return "", fmt.Errorf("%w. branch=%s, ahead=%d, behind=%d", ErrDivergedHistory, i.status.LocalBranch(), commits.Ahead, commits.Behind)
+		case commits.Ahead > 0:
+			slog.Warn("Project has local commits which are not on the remote.", "name", i.Name, "ahead", commits.Ahead)
+			return stateDone, nil
+		case commits.Behind == 0:
+			slog.Info("Project is already up to date.", "name", i.Name, "branch", i.status.LocalBranch())
+			return stateDone, nil
+		}
+		slog.Info("Pulling project.", "name", i.Name, "branch", i.status.LocalBranch(), "behind", commits.Behind)
+		_, err := i.Client.ProjectPull(i.Name)
+		return stateDone, err
+
+	case stateTrack:
+		if i.Project.Branch != "" || i.branchCheckedOut {
+			return "", fmt.Errorf("%w. branch=%s", ErrNoTrackedBranch, i.status.LocalBranch())
+		}
+		branches, err := i.Client.ProjectBranches(i.Name)
+		if err != nil {
+			return "", err
+		}
+		tracked := make([]string, 0)
+		for _, branch := range branches.Branches {
+			if branch.Remote != "" {
+				tracked = append(tracked, branch.Name)
+			}
+		}
+		if len(tracked) != 1 {
+			return "", fmt.Errorf("%w. branch=%s, tracked=%s", ErrNoTrackedBranch, i.status.LocalBranch(), strings.Join(tracked, ","))
+		}
+		slog.Info("Checking out tracked branch.", "name", i.Name, "branch", tracked[0])
+		if _, err := i.Client.ProjectCheckout(i.Name, tracked[0]); err != nil {
+			return "", err
+		}
+		i.branchCheckedOut = true
+		return stateFetch, nil
+	}
+	return "", fmt.Errorf("invalid state. state=%s", state)
+}
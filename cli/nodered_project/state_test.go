@@ -0,0 +1,259 @@
+package nodered_project
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+// projectAPI is a stub of the node-red projects api which records the changes made to the projects
+type projectAPI struct {
+	mu       sync.Mutex
+	projects []string
+	active   string
+	status   nodered.ProjectStatus
+	branches []nodered.Branch
+	calls    []string
+
+	// node-red activates a project when it is cloned
+	activateOnClone bool
+}
+
+func (s *projectAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body := map[string]any{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	name, action := "", ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 {
+		action = parts[2]
+	}
+
+	var resp any = map[string]any{}
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		resp = nodered.ProjectsResponse{Projects: s.projects, Active: s.active}
+	case r.Method == http.MethodPost && name == "":
+		name, _ = body["name"].(string)
+		remote := body["git"].(map[string]any)["remotes"].(map[string]any)["origin"].(map[string]any)
+		branch, _ := remote["branch"].(string)
+		s.calls = append(s.calls, strings.TrimSpace("clone "+name+" "+branch))
+		s.projects = append(s.projects, name)
+		if s.activateOnClone {
+			s.active = name
+		}
+	case r.Method == http.MethodPut && action == "":
+		if body["active"] == true {
+			s.calls = append(s.calls, "activate "+name)
+			s.active = name
+		}
+	case r.Method == http.MethodGet && action == "status":
+		if r.URL.Query().Get("remote") == "true" {
+			s.calls = append(s.calls, "fetch "+name)
+		}
+		resp = s.status
+	case r.Method == http.MethodGet && action == "branches":
+		resp = nodered.Branches{Branches: s.branches}
+	case r.Method == http.MethodPost && action == "branches":
+		ref, _ := body["name"].(string)
+		s.calls = append(s.calls, "checkout "+name+" "+ref)
+		s.status.Branches = map[string]string{"local": ref}
+		for _, branch := range s.branches {
+			if branch.Name == ref {
+				s.status.Branches["remote"] = branch.Remote
+				s.status.Commits = *branch.Status
+			}
+		}
+	case r.Method == http.MethodPost && action == "pull":
+		s.calls = append(s.calls, "pull "+name)
+	case r.Method == http.MethodPost && action == "discard":
+		s.calls = append(s.calls, "discard "+name)
+	case r.Method == http.MethodDelete:
+		s.calls = append(s.calls, "delete "+action+" "+name)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func tracking(local string, remote string, behind int64) nodered.ProjectStatus {
+	return nodered.ProjectStatus{
+		Branches: map[string]string{"local": local, "remote": remote},
+		Commits:  nodered.BranchStatus{Behind: behind},
+	}
+}
+
+func TestInstaller(t *testing.T) {
+	tests := []struct {
+		name      string
+		api       *projectAPI
+		project   ProjectDescription
+		version   string
+		force     bool
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name:      "clone new project",
+			api:       &projectAPI{activateOnClone: true},
+			version:   "latest",
+			wantCalls: []string{"clone a"},
+		},
+		{
+			name:      "clone new project which is not activated by node-red",
+			api:       &projectAPI{},
+			version:   "latest",
+			wantCalls: []string{"clone a", "activate a"},
+		},
+		{
+			name:      "clone new project pinned to a tag",
+			api:       &projectAPI{activateOnClone: true},
+			project:   ProjectDescription{TagFormat: "v{version}"},
+			version:   "1.0.0",
+			wantCalls: []string{"clone a v1.0.0"},
+		},
+		{
+			name:      "clone new project pinned to a commit",
+			api:       &projectAPI{activateOnClone: true},
+			project:   ProjectDescription{Ref: "abcdef1"},
+			wantCalls: []string{"clone a", "checkout a abcdef1"},
+		},
+		{
+			name:      "activate and update inactive project",
+			api:       &projectAPI{projects: []string{"a", "b"}, active: "b", status: tracking("main", "origin/main", 2)},
+			wantCalls: []string{"activate a", "fetch a", "pull a"},
+		},
+		{
+			name:      "active project is up to date",
+			api:       &projectAPI{projects: []string{"a"}, active: "a", status: tracking("main", "origin/main", 0)},
+			wantCalls: []string{"fetch a"},
+		},
+		{
+			name:      "existing project pinned to a tag",
+			api:       &projectAPI{projects: []string{"a"}, active: "a", status: tracking("main", "origin/main", 2)},
+			project:   ProjectDescription{TagFormat: "v{version}"},
+			version:   "2.0.0",
+			wantCalls: []string{"fetch a", "checkout a v2.0.0"},
+		},
+		{
+			name: "check out the configured branch",
+			api: &projectAPI{
+				projects: []string{"a"},
+				active:   "a",
+				status:   tracking("main", "origin/main", 0),
+				branches: []nodered.Branch{{Name: "main", Remote: "origin/main", Status: &nodered.BranchStatus{}}, {Name: "dev", Remote: "origin/dev", Status: &nodered.BranchStatus{Behind: 1}}},
+			},
+			project:   ProjectDescription{Branch: "dev"},
+			wantCalls: []string{"fetch a", "checkout a dev", "fetch a", "pull a"},
+		},
+		{
+			name: "detached project checks out the tracked branch",
+			api: &projectAPI{
+				projects: []string{"a"},
+				active:   "a",
+				status:   tracking("", "", 0),
+				branches: []nodered.Branch{{Name: "main", Remote: "origin/main", Status: &nodered.BranchStatus{Behind: 3}}},
+			},
+			version:   "latest",
+			wantCalls: []string{"fetch a", "checkout a main", "fetch a", "pull a"},
+		},
+		{
+			name: "detached project without a tracked branch",
+			api: &projectAPI{
+				projects: []string{"a"},
+				active:   "a",
+				status:   tracking("", "", 0),
+				branches: []nodered.Branch{{Name: "local-only"}},
+			},
+			wantCalls: []string{"fetch a"},
+			wantErr:   ErrNoTrackedBranch,
+		},
+		{
+			name:      "configured branch without a remote",
+			api:       &projectAPI{projects: []string{"a"}, active: "a", status: tracking("dev", "", 0)},
+			project:   ProjectDescription{Branch: "dev"},
+			wantCalls: []string{"fetch a"},
+			wantErr:   ErrNoTrackedBranch,
+		},
+		{
+			name: "local changes",
+			api: &projectAPI{projects: []string{"a"}, active: "a", status: nodered.ProjectStatus{
+				Branches: map[string]string{"local": "main", "remote": "origin/main"},
+				Files:    map[string]nodered.FileStatus{"flows.json": {Status: " M"}},
+			}},
+			wantCalls: []string{"fetch a"},
+			wantErr:   ErrLocalChanges,
+		},
+		{
+			name: "discard local changes",
+			api: &projectAPI{projects: []string{"a"}, active: "a", status: nodered.ProjectStatus{
+				Branches: map[string]string{"local": "main", "remote": "origin/main"},
+				Files:    map[string]nodered.FileStatus{"flows.json": {Status: " M"}, "new.txt": {Status: "??"}},
+			}},
+			force:     true,
+			wantCalls: []string{"fetch a", "delete stage a", "discard a"},
+		},
+		{
+			name: "abort merge",
+			api: &projectAPI{projects: []string{"a"}, active: "a", status: nodered.ProjectStatus{
+				Branches: map[string]string{"local": "main", "remote": "origin/main"},
+				Merging:  true,
+			}},
+			force:     true,
+			wantCalls: []string{"fetch a", "delete merge a"},
+		},
+		{
+			name: "diverged history",
+			api: &projectAPI{projects: []string{"a"}, active: "a", status: nodered.ProjectStatus{
+				Branches: map[string]string{"local": "main", "remote": "origin/main"},
+				Commits:  nodered.BranchStatus{Ahead: 1, Behind: 1},
+			}},
+			wantCalls: []string{"fetch a"},
+			wantErr:   ErrDivergedHistory,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.api)
+			defer server.Close()
+
+			project := tt.project
+			project.Repository = "https://example.com/a.git"
+			installer := &Installer{
+				Client:  nodered.NewClientWithoutRetries(server.URL),
+				Name:    "a",
+				Project: &project,
+				Version: tt.version,
+				Force:   tt.force,
+			}
+			err := installer.Run()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Run() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Run() unexpected error. %v", err)
+			}
+			if !slices.Equal(tt.api.calls, tt.wantCalls) {
+				t.Errorf("Run() calls = %v, want %v", tt.api.calls, tt.wantCalls)
+			}
+			if err == nil && tt.api.active != "a" {
+				t.Errorf("Run() active project = %s, want a", tt.api.active)
+			}
+		})
+	}
+}
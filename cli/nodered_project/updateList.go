@@ -4,21 +4,196 @@ Copyright © 2024 thin-edge.io <info@thin-edge.io>
 package nodered_project
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
-	"os"
+	"slices"
 
 	"github.com/spf13/cobra"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
 )
 
+// ErrInactiveProject is returned when a batch requires changing a project which is not activated by the batch
+var ErrInactiveProject = errors.New("only the last installed project of the batch is activated, so the other installed projects must be new and can not be pinned to a commit")
+
 // updateListCmd represents the updateList command
 func NewUpdateListCommand(ctx cli.Cli) *cobra.Command {
-	return &cobra.Command{
-		Use:   "update-list",
-		Short: "Not implemented",
-		Run: func(cmd *cobra.Command, args []string) {
-			slog.Info("update-list is not supported")
-			os.Exit(1)
+	force := false
+	cmd := &cobra.Command{
+		Use:   cli.UpdateListCommand,
+		Short: "Install and remove multiple projects with a single activation",
+		Long: `Install and remove multiple projects with a single activation.
+
+The list of actions is read from stdin, where each line is in the form of:
+	install	<MODULE_NAME>	<MODULE_VERSION>	<FILE>
+	remove	<MODULE_NAME>	<MODULE_VERSION>
+
+The projects are cloned or deleted in order, however only the project
+which is left active by the batch (the last installed project) is
+activated, and it is installed last. node-red only supports updating the
+active project, so the batch is rejected if any of the other installed
+projects already exists or is pinned to a commit. Deleting the active
+project is deferred until the end of the batch.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			actions, err := cli.ParseUpdateList(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if len(actions) == 0 {
+				slog.Info("Nothing to update.")
+				return nil
+			}
+
+			// Read all artifacts before changing anything
+			descriptions := make(map[string]*ProjectDescription)
+			versions := make(map[string]string)
+			paths := make(map[string]string)
+			for _, action := range actions {
+				if action.Action != cli.ActionInstall {
+					continue
+				}
+				project, err := ReadProjectDescription(action.Path)
+				if err != nil {
+					return fmt.Errorf("invalid artifact. name=%s, version=%s. %w", action.Name, action.Version, err)
+				}
+				descriptions[action.Name] = project
+				versions[action.Name] = action.Version
+				paths[action.Name] = action.Path
+			}
+
+			client, err := cli.NewClientWithRetries(cmd.Context(), cli.GetAPI())
+			if err != nil {
+				return err
+			}
+			projects, err := client.ProjectList()
+			if err != nil {
+				return err
+			}
+
+			// Check that the batch can be applied, and find the project which it leaves active
+			installed := slices.Clone(projects.Projects)
+			target := ""
+			for _, action := range actions {
+				switch action.Action {
+				case cli.ActionInstall:
+					if !slices.Contains(installed, action.Name) {
+						installed = append(installed, action.Name)
+					}
+					target = action.Name
+				case cli.ActionRemove:
+					if !slices.Contains(installed, action.Name) {
+						return fmt.Errorf("%w. name=%s", nodered.ErrModuleNotInstalled, action.Name)
+					}
+					installed = slices.DeleteFunc(installed, func(v string) bool { return v == action.Name })
+					if target == action.Name {
+						target = ""
+					}
+				}
+			}
+
+			// Only the target project is activated, so all other installed projects are only cloned
+			existing := slices.Clone(projects.Projects)
+			for _, action := range actions {
+				switch action.Action {
+				case cli.ActionInstall:
+					if action.Name == target {
+						continue
+					}
+					if slices.Contains(existing, action.Name) {
+						return fmt.Errorf("%w. name=%s, reason=project already exists", ErrInactiveProject, action.Name)
+					}
+					if ref := descriptions[action.Name].GetRef(action.Version); IsCommit(ref) {
+						return fmt.Errorf("%w. name=%s, ref=%s, reason=commits can only be checked out once the project is active", ErrInactiveProject, action.Name, ref)
+					}
+					existing = append(existing, action.Name)
+				case cli.ActionRemove:
+					existing = slices.DeleteFunc(existing, func(v string) bool { return v == action.Name })
+				}
+			}
+
+			// node-red activates a project when it is cloned
+			active := projects.Active
+			refreshActive := func() error {
+				projects, err := client.ProjectList()
+				if err != nil {
+					return err
+				}
+				active = projects.Active
+				return nil
+			}
+
+			deferred := make([]string, 0)
+			for _, action := range actions {
+				switch action.Action {
+				case cli.ActionRemove:
+					if action.Name == active {
+						// node-red does not allow deleting the active project
+						deferred = append(deferred, action.Name)
+						continue
+					}
+					slog.Info("Deleting project.", "name", action.Name)
+					if err := client.ProjectDelete(action.Name); err != nil {
+						return err
+					}
+					ForgetProject(action.Name)
+
+				case cli.ActionInstall:
+					if action.Name == target {
+						// The target project is installed last
+						continue
+					}
+					slog.Info("Cloning new project.", "name", action.Name)
+					if err := CloneProject(client, action.Name, descriptions[action.Name], action.Version); err != nil {
+						return err
+					}
+					RecordProject(action.Name, action.Version, action.Path)
+					if err := refreshActive(); err != nil {
+						return err
+					}
+				}
+			}
+
+			if target != "" {
+				installer := &Installer{
+					Client:  client,
+					Name:    target,
+					Project: descriptions[target],
+					Version: versions[target],
+					Force:   force || GetForceEnabled(),
+				}
+				if err := installer.Run(); err != nil {
+					return err
+				}
+				// The installer always leaves the project active
+				active = target
+				RecordActiveProject(client, target, versions[target], paths[target])
+			}
+
+			for _, name := range deferred {
+				if name == target {
+					// The project was installed again later in the batch
+					continue
+				}
+				if name == active {
+					if err := ReleaseActiveProject(client, name); err != nil {
+						return err
+					}
+				}
+				slog.Info("Deleting project.", "name", name)
+				if err := client.ProjectDelete(name); err != nil {
+					return err
+				}
+				ForgetProject(name)
+			}
+
+			return ReportActiveProject(client)
 		},
 	}
+	cmd.Flags().BoolVar(&force, "force", false, "Discard local changes of existing projects")
+	return cmd
 }
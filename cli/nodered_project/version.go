@@ -0,0 +1,241 @@
+/*
+Copyright © 2024 thin-edge.io <info@thin-edge.io>
+*/
+package nodered_project
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+// GetUserDir returns the node-red user directory, which is used to read the versions
+// of inactive projects. An empty value disables reading the user directory
+func GetUserDir() string {
+	return viper.GetString("nodered.user_dir")
+}
+
+// SoftwareType is the software type used to record the projects in the registry
+const SoftwareType = "nodered-project"
+
+// RecordProject records an installed project in the registry
+func RecordProject(name string, version string, path string) {
+	checksum, err := registry.Checksum(path)
+	if err != nil {
+		slog.Warn("Could not calculate checksum.", "path", path, "err", err)
+	}
+	cli.UpdateRegistry(func(r *registry.Registry) {
+		r.Put(SoftwareType, &registry.Module{
+			Name:     name,
+			Version:  version,
+			Checksum: checksum,
+			Ref:      pinnedRef(path, version),
+		})
+	})
+}
+
+// pinnedRef returns the tag or commit which the version of the project artifact is pinned to
+func pinnedRef(path string, version string) string {
+	project, err := ReadProjectDescription(path)
+	if err != nil {
+		return ""
+	}
+	return project.GetRef(version)
+}
+
+// RecordActiveProject records the active project after it has been installed, using the
+// version reported by node-red. The requested version is used if it can't be read
+func RecordActiveProject(client *nodered.Client, name string, version string, path string) {
+	installed := &registry.Module{Version: version, Ref: pinnedRef(path, version)}
+	if activeVersion, err := GetActiveVersion(client, name, installed); err == nil && activeVersion != "" {
+		version = activeVersion
+	}
+	RecordProject(name, version, path)
+}
+
+// ForgetProject removes a deleted project from the registry
+func ForgetProject(name string) {
+	cli.UpdateRegistry(func(r *registry.Registry) {
+		r.Delete(SoftwareType, name)
+	})
+}
+
+// ReadInactiveVersion reads the version of a project which is not active from the node-red
+// user directory, as node-red only supports reading the active project. An empty value is
+// returned if the user directory is not configured or the version can't be read
+func ReadInactiveVersion(name string, installed *registry.Module) string {
+	userDir := GetUserDir()
+	if userDir == "" {
+		return ""
+	}
+	projectDir := filepath.Join(userDir, "projects", name)
+	var version string
+	var err error
+	switch GetVersionSource() {
+	case VersionSourceGit:
+		var head *GitHead
+		if head, err = ReadGitHead(projectDir); err == nil {
+			version = head.PinnedVersion(installed)
+		}
+	default:
+		version, err = ReadPackageVersion(projectDir)
+	}
+	if err != nil {
+		slog.Debug("Could not read project version from the user directory.", "name", name, "err", err)
+		return ""
+	}
+	return version
+}
+
+// LiveModules returns the installed projects. The version of inactive projects is only
+// included if it can be read from the node-red user directory
+func LiveModules(client *nodered.Client) ([]registry.LiveModule, error) {
+	projects, err := client.ProjectList()
+	if err != nil {
+		return nil, err
+	}
+	return liveProjects(client, projects)
+}
+
+func liveProjects(client *nodered.Client, projects *nodered.ProjectsResponse) ([]registry.LiveModule, error) {
+	installed, err := cli.OpenRegistry()
+	if err != nil {
+		slog.Warn("Could not open registry.", "err", err)
+		installed = registry.New(cli.GetRegistryPath())
+	}
+
+	names := slices.Sorted(slices.Values(projects.Projects))
+	live := make([]registry.LiveModule, 0, len(names))
+	for _, name := range names {
+		version := ""
+		module, _ := installed.Get(SoftwareType, name)
+		if name == projects.Active {
+			// nodered only supports getting info for the active project
+			activeVersion, err := GetActiveVersion(client, name, module)
+			if err != nil {
+				return nil, err
+			}
+			version = activeVersion
+		} else {
+			version = ReadInactiveVersion(name, module)
+		}
+		live = append(live, registry.LiveModule{
+			Name:    name,
+			Version: version,
+		})
+	}
+	return live, nil
+}
+
+// ReadPackageVersion reads the version from the package.json of the project
+func ReadPackageVersion(projectDir string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(projectDir, "package.json"))
+	if err != nil {
+		return "", err
+	}
+	pkg := struct {
+		Version string `json:"version"`
+	}{}
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return "", err
+	}
+	return pkg.Version, nil
+}
+
+// ReadGitHead reads the checked out commit of a project from its git directory
+func ReadGitHead(projectDir string) (*GitHead, error) {
+	gitDir := filepath.Join(projectDir, ".git")
+	sha, err := resolveGitHead(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	return &GitHead{Sha: sha, Tags: findGitTags(gitDir, sha)}, nil
+}
+
+func resolveGitHead(gitDir string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+	head := strings.TrimSpace(string(b))
+	ref, ok := strings.CutPrefix(head, "ref: ")
+	if !ok {
+		// detached HEAD
+		return head, nil
+	}
+	if b, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(b)), nil
+	}
+	refs := readPackedRefs(gitDir)
+	if sha, ok := refs[ref]; ok {
+		return sha, nil
+	}
+	return "", fmt.Errorf("could not resolve git ref. ref=%s", ref)
+}
+
+// readPackedRefs returns the sha of each packed ref. Annotated tags use the sha of the tagged commit
+func readPackedRefs(gitDir string) map[string]string {
+	refs := make(map[string]string)
+	file, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return refs
+	}
+	defer file.Close()
+
+	previous := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if peeled, ok := strings.CutPrefix(line, "^"); ok {
+			if previous != "" {
+				refs[previous] = peeled
+			}
+			continue
+		}
+		if sha, ref, ok := strings.Cut(line, " "); ok {
+			refs[ref] = sha
+			previous = ref
+		}
+	}
+	return refs
+}
+
+func findGitTags(gitDir string, sha string) []string {
+	tagsDir := filepath.Join(gitDir, "refs", "tags")
+	tags := make([]string, 0)
+	_ = filepath.WalkDir(tagsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil || strings.TrimSpace(string(b)) != sha {
+			// Note: annotated tags which are not packed are not supported
+			return nil
+		}
+		if rel, err := filepath.Rel(tagsDir, path); err == nil {
+			tags = append(tags, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	packed := make([]string, 0)
+	for ref, refSHA := range readPackedRefs(gitDir) {
+		if name, ok := strings.CutPrefix(ref, "refs/tags/"); ok && refSHA == sha && !slices.Contains(tags, name) {
+			packed = append(packed, name)
+		}
+	}
+	slices.Sort(packed)
+	return append(tags, packed...)
+}
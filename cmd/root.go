@@ -4,15 +4,21 @@ Copyright © 2024 thin-edge.io <info@thin-edge.io>
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/cli/nodered_auth"
 	"github.com/thin-edge/tedge-nodered-plugin/cli/nodered_flow"
+	"github.com/thin-edge/tedge-nodered-plugin/cli/nodered_nodes"
 	"github.com/thin-edge/tedge-nodered-plugin/cli/nodered_project"
 	"github.com/thin-edge/tedge-nodered-plugin/pkg/cli"
 )
@@ -29,10 +35,18 @@ var rootCmd = &cobra.Command{
 	Short:   "thin-edge.io nodered plugin",
 	Version: fmt.Sprintf("%s (branch=%s)", buildVersion, buildBranch),
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		return SetLogLevel()
+		if err := SetLogLevel(); err != nil {
+			return err
+		}
+		cmd.SetContext(cli.WithCommandName(cmd.Context(), cmd.Name()))
+		SetOperationTimeout(cmd)
+		return nil
 	},
 }
 
+// cancelOperation releases the resources of the operation timeout
+var cancelOperation context.CancelFunc = func() {}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -49,8 +63,17 @@ func Execute() {
 		}
 	}
 
-	err := rootCmd.Execute()
+	// Cancel any in-flight requests when thin-edge.io (or the user) stops the operation
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	cancelOperation()
+	stop()
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			slog.Error("Operation timed out.", "timeout", viper.GetDuration("nodered.timeout"))
+		} else if errors.Is(err, context.Canceled) {
+			slog.Error("Operation was cancelled.")
+		}
 		switch err.(type) {
 		case cli.SilentError:
 			// Don't log error
@@ -58,7 +81,12 @@ func Execute() {
 		default:
 			slog.Error("Command error", "err", err)
 		}
-		os.Exit(1)
+
+		// thin-edge.io includes the output in the failure reason of the operation
+		if reason := cli.FailureReason(err); reason != "" {
+			fmt.Fprintf(rootCmd.ErrOrStderr(), "Reason: %s\n", reason)
+		}
+		os.Exit(cli.ExitCode(cmd, err))
 	}
 }
 
@@ -78,6 +106,19 @@ func SetLogLevel() error {
 	return nil
 }
 
+// SetOperationTimeout limits the duration of the whole operation (including retries) to the
+// configured timeout, so that an unresponsive node-red does not block the operation indefinitely
+func SetOperationTimeout(cmd *cobra.Command) {
+	timeout := viper.GetDuration("nodered.timeout")
+	if timeout <= 0 {
+		return
+	}
+	slog.Debug("Setting operation timeout.", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	cmd.SetContext(ctx)
+	cancelOperation = cancel
+}
+
 func init() {
 	cliConfig := cli.Cli{}
 	cobra.OnInitialize(func() {
@@ -86,6 +127,8 @@ func init() {
 	rootCmd.AddCommand(
 		nodered_flow.NewCommand(cliConfig),
 		nodered_project.NewCommand(cliConfig),
+		nodered_nodes.NewCommand(cliConfig),
+		nodered_auth.NewCommand(cliConfig),
 	)
 
 	// Don't show usage on errors
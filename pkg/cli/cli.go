@@ -12,6 +12,7 @@ import (
 )
 
 var LinuxConfigFilePath = "/etc/tedge/plugins/tedge-nodered-plugin.toml"
+var DefaultStateDir = "/var/lib/tedge-nodered-plugin"
 
 type SilentError error
 
@@ -57,6 +58,15 @@ func (c *Cli) GetBool(key string) bool {
 	return viper.GetBool(key)
 }
 
+// GetStateDir returns the directory where the plugin can persist its own state
+func GetStateDir() string {
+	v := viper.GetString("state_dir")
+	if v == "" {
+		v = DefaultStateDir
+	}
+	return v
+}
+
 func (c *Cli) PrintConfig() {
 	keys := viper.AllKeys()
 	sort.Strings(keys)
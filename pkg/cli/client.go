@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+// GetAPI returns the url of the node-red admin api
+func GetAPI() string {
+	v := viper.GetString("nodered.api")
+	if v == "" {
+		v = "http://127.0.0.1:1880"
+	}
+	return v
+}
+
+// NewClientWithRetries creates a node-red client which uses the plugin's settings.
+// All requests are cancelled when the context is done
+func NewClientWithRetries(ctx context.Context, baseURL string) (*nodered.Client, error) {
+	client := nodered.NewClientWithRetries(baseURL).
+		SetContext(ctx).
+		SetRetryPolicy(GetRetryPolicy(CommandName(ctx)))
+	return ConfigureClient(client)
+}
+
+// NewClientWithoutRetries creates a node-red client which uses the plugin's settings.
+// All requests are cancelled when the context is done
+func NewClientWithoutRetries(ctx context.Context, baseURL string) (*nodered.Client, error) {
+	return ConfigureClient(nodered.NewClientWithoutRetries(baseURL).SetContext(ctx))
+}
+
+type commandNameKey struct{}
+
+// WithCommandName adds the name of the command being executed to the context
+func WithCommandName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, commandNameKey{}, name)
+}
+
+// CommandName returns the name of the command being executed
+func CommandName(ctx context.Context) string {
+	name, _ := ctx.Value(commandNameKey{}).(string)
+	return name
+}
+
+// retrySetting returns the key of a retry setting, where the command specific setting,
+// e.g. nodered.retry.install.count, takes precedence over the general setting
+func retrySetting(command string, name string) string {
+	if command != "" {
+		if key := "nodered.retry." + command + "." + name; viper.IsSet(key) {
+			return key
+		}
+	}
+	return "nodered.retry." + name
+}
+
+// GetRetryPolicy returns the retry policy of a command
+func GetRetryPolicy(command string) nodered.RetryPolicy {
+	policy := nodered.DefaultRetryPolicy()
+	if key := retrySetting(command, "count"); viper.IsSet(key) {
+		policy.Count = viper.GetInt(key)
+	}
+	if key := retrySetting(command, "wait"); viper.IsSet(key) {
+		policy.WaitTime = viper.GetDuration(key)
+	}
+	if key := retrySetting(command, "max_wait"); viper.IsSet(key) {
+		policy.MaxWaitTime = viper.GetDuration(key)
+	}
+	if key := retrySetting(command, "jitter"); viper.IsSet(key) {
+		policy.Jitter = viper.GetDuration(key)
+	}
+	if key := retrySetting(command, "status_codes"); viper.IsSet(key) {
+		policy.StatusCodes = viper.GetIntSlice(key)
+	}
+	slog.Debug("Using retry policy.", "command", command, "count", policy.Count, "wait", policy.WaitTime, "max_wait", policy.MaxWaitTime, "jitter", policy.Jitter, "status_codes", policy.StatusCodes)
+	return policy
+}
+
+// ConfigureClient applies the plugin's settings (e.g. authentication and tls) to a node-red client
+func ConfigureClient(client *nodered.Client) (*nodered.Client, error) {
+	if token := viper.GetString("nodered.auth.token"); token != "" {
+		client.SetToken(token)
+	}
+	if username := viper.GetString("nodered.auth.username"); username != "" {
+		client.SetCredentials(username, viper.GetString("nodered.auth.password"))
+		client.SetTokenCacheFile(filepath.Join(GetStateDir(), "auth", "token.json"))
+	}
+
+	tlsOptions := nodered.TLSOptions{
+		CAFile:             viper.GetString("nodered.tls.ca_file"),
+		CertFile:           viper.GetString("nodered.tls.cert_file"),
+		KeyFile:            viper.GetString("nodered.tls.key_file"),
+		ServerName:         viper.GetString("nodered.tls.server_name"),
+		InsecureSkipVerify: viper.GetBool("nodered.tls.insecure_skip_verify"),
+	}
+	if !tlsOptions.IsEmpty() {
+		tlsConfig, err := nodered.NewTLSConfig(tlsOptions)
+		if err != nil {
+			return nil, err
+		}
+		if tlsOptions.InsecureSkipVerify {
+			slog.Warn("TLS certificate verification is disabled.")
+		}
+		client.SetTLSConfig(tlsConfig)
+	}
+	return client, nil
+}
+
+// WaitForReady waits until node-red's runtime has started, using the plugin's settings
+func WaitForReady(client *nodered.Client) error {
+	viper.SetDefault("nodered.ready.timeout", "60s")
+	viper.SetDefault("nodered.ready.interval", "2s")
+	return client.WaitForReady(
+		viper.GetDuration("nodered.ready.timeout"),
+		viper.GetDuration("nodered.ready.interval"),
+	)
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+func TestGetRetryPolicy(t *testing.T) {
+	defaults := nodered.DefaultRetryPolicy()
+	tests := []struct {
+		name     string
+		settings map[string]any
+		command  string
+		want     nodered.RetryPolicy
+	}{
+		{
+			name:    "defaults",
+			command: "install",
+			want:    defaults,
+		},
+		{
+			name: "general settings",
+			settings: map[string]any{
+				"nodered.retry.count":        5,
+				"nodered.retry.wait":         "1s",
+				"nodered.retry.max_wait":     "5s",
+				"nodered.retry.jitter":       "0s",
+				"nodered.retry.status_codes": []int{503},
+			},
+			command: "install",
+			want:    nodered.RetryPolicy{Count: 5, WaitTime: time.Second, MaxWaitTime: 5 * time.Second, Jitter: 0, StatusCodes: []int{503}},
+		},
+		{
+			name: "command specific settings take precedence",
+			settings: map[string]any{
+				"nodered.retry.count":         5,
+				"nodered.retry.wait":          "1s",
+				"nodered.retry.list.count":    0,
+				"nodered.retry.list.max_wait": "2s",
+			},
+			command: "list",
+			want:    nodered.RetryPolicy{Count: 0, WaitTime: time.Second, MaxWaitTime: 2 * time.Second, Jitter: defaults.Jitter, StatusCodes: defaults.StatusCodes},
+		},
+		{
+			name: "settings of other commands are ignored",
+			settings: map[string]any{
+				"nodered.retry.count":      5,
+				"nodered.retry.list.count": 0,
+			},
+			command: "install",
+			want:    nodered.RetryPolicy{Count: 5, WaitTime: defaults.WaitTime, MaxWaitTime: defaults.MaxWaitTime, Jitter: defaults.Jitter, StatusCodes: defaults.StatusCodes},
+		},
+		{
+			name: "unknown command",
+			settings: map[string]any{
+				"nodered.retry.count": 5,
+			},
+			want: nodered.RetryPolicy{Count: 5, WaitTime: defaults.WaitTime, MaxWaitTime: defaults.MaxWaitTime, Jitter: defaults.Jitter, StatusCodes: defaults.StatusCodes},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			for key, value := range tt.settings {
+				viper.Set(key, value)
+			}
+
+			got := GetRetryPolicy(tt.command)
+			if got.Count != tt.want.Count || got.WaitTime != tt.want.WaitTime || got.MaxWaitTime != tt.want.MaxWaitTime || got.Jitter != tt.want.Jitter || !slices.Equal(got.StatusCodes, tt.want.StatusCodes) {
+				t.Errorf("GetRetryPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
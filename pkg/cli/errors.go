@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+// Exit codes of the plugin, which allow scripts to handle specific failures. thin-edge.io treats
+// any non-zero exit code as a failure, except for update-list, where exit code 1 means that
+// update-list is not supported, so update-list must never use ExitFailure
+const (
+	ExitSuccess = 0
+	ExitFailure = 1
+	// ExitUpdateListFailure is used by update-list instead of ExitFailure, as thin-edge.io treats exit code 1
+	// of update-list as "not supported", and then falls back to installing/removing each module separately
+	ExitUpdateListFailure = 2
+	ExitUnauthorized      = 3
+	ExitConflict          = 4
+	ExitMissingTypes      = 5
+	ExitUnavailable       = 6
+	ExitValidation        = 7
+)
+
+type failure struct {
+	match  func(error) bool
+	code   int
+	reason string
+}
+
+func is(target error) func(error) bool {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// The first matching failure is used, so the more specific errors must come first
+var failures = []failure{
+	{is(nodered.ErrUnauthorized), ExitUnauthorized, "node-red rejected the credentials. Check the nodered.auth settings"},
+	{is(nodered.ErrConflict), ExitConflict, "the flows were changed in node-red while they were being deployed. Retry the operation"},
+	{is(nodered.ErrMissingTypes), ExitMissingTypes, "the flows use node types which are not installed. Install the required palette modules first"},
+	{nodered.IsUnavailable, ExitUnavailable, "node-red is not available. Check that node-red is running"},
+	{is(nodered.ErrValidation), ExitValidation, "node-red rejected the request as invalid. Check the artifact"},
+}
+
+// ExitCode returns the exit code for an error of the given command
+func ExitCode(cmd *cobra.Command, err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	for _, f := range failures {
+		if f.match(err) {
+			return f.code
+		}
+	}
+	if cmd != nil && cmd.Name() == UpdateListCommand {
+		return ExitUpdateListFailure
+	}
+	return ExitFailure
+}
+
+// FailureReason returns a human readable reason for an error, which is included in the
+// output of the plugin, and therefore also in the failure reason of the operation.
+// An empty value is returned if the error is not a known failure
+func FailureReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, f := range failures {
+		if f.match(err) {
+			return f.reason
+		}
+	}
+	return ""
+}
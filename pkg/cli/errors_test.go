@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/nodered"
+)
+
+func TestExitCode(t *testing.T) {
+	install := &cobra.Command{Use: "install"}
+	updateList := &cobra.Command{Use: UpdateListCommand}
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name       string
+		err        error
+		want       int
+		wantReason bool
+	}{
+		{name: "success", err: nil, want: ExitSuccess},
+		{name: "generic error", err: errors.New("invalid artifact"), want: ExitFailure},
+		{name: "unauthorized", err: nodered.NewServerError(http.StatusUnauthorized, nil), want: ExitUnauthorized, wantReason: true},
+		{name: "forbidden", err: nodered.NewServerError(http.StatusForbidden, nil), want: ExitUnauthorized, wantReason: true},
+		{name: "no credentials", err: nodered.ErrNoCredentials, want: ExitUnauthorized, wantReason: true},
+		{name: "conflict", err: nodered.NewServerError(http.StatusConflict, nil), want: ExitConflict, wantReason: true},
+		{name: "missing node types", err: fmt.Errorf("%w. types=foo", nodered.ErrMissingTypes), want: ExitMissingTypes, wantReason: true},
+		{name: "service unavailable", err: nodered.NewServerError(http.StatusServiceUnavailable, nil), want: ExitUnavailable, wantReason: true},
+		{name: "not ready", err: nodered.ErrNotReady, want: ExitUnavailable, wantReason: true},
+		{name: "connection refused", err: fmt.Errorf("request failed. %w", dialErr), want: ExitUnavailable, wantReason: true},
+		{name: "bad request", err: nodered.NewServerError(http.StatusBadRequest, []byte(`{"code":"invalid_request","message":"Invalid request"}`)), want: ExitValidation, wantReason: true},
+		{name: "not found", err: nodered.NewServerError(http.StatusNotFound, nil), want: ExitFailure},
+		{name: "internal server error", err: nodered.NewServerError(http.StatusInternalServerError, nil), want: ExitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(install, tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+			if got := FailureReason(tt.err); (got != "") != tt.wantReason {
+				t.Errorf("FailureReason() = %q, want a reason: %v", got, tt.wantReason)
+			}
+		})
+	}
+
+	// thin-edge.io treats exit code 1 of update-list as "not supported"
+	for _, tt := range tests {
+		t.Run("update-list/"+tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == ExitFailure {
+				want = ExitUpdateListFailure
+			}
+			got := ExitCode(updateList, tt.err)
+			if got == ExitFailure {
+				t.Errorf("ExitCode() = %d, update-list must never exit with %d", got, ExitFailure)
+			}
+			if got != want {
+				t.Errorf("ExitCode() = %d, want %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/registry"
+)
+
+// GetRegistryPath returns the path of the registry of the modules installed by the plugin
+func GetRegistryPath() string {
+	return filepath.Join(GetStateDir(), "registry.json")
+}
+
+func OpenRegistry() (*registry.Registry, error) {
+	return registry.Open(GetRegistryPath())
+}
+
+// UpdateRegistry applies the changes to the registry and saves it. Failures are only logged,
+// as the registry must not fail an operation which was already applied to node-red
+func UpdateRegistry(update func(r *registry.Registry)) {
+	r, err := OpenRegistry()
+	if err != nil {
+		slog.Warn("Could not open registry.", "err", err)
+		return
+	}
+	update(r)
+	if err := r.Save(); err != nil {
+		slog.Warn("Could not save registry.", "path", GetRegistryPath(), "err", err)
+	}
+}
+
+// ReconcileRegistry reconciles the registry with the live modules of a software type and logs any drift.
+// The registry is only saved if the reconciliation changed it
+func ReconcileRegistry(softwareType string, live []registry.LiveModule) (*registry.Registry, []registry.Drift) {
+	r, err := OpenRegistry()
+	if err != nil {
+		slog.Warn("Could not open registry.", "err", err)
+		return registry.New(GetRegistryPath()), nil
+	}
+	drift := r.Reconcile(softwareType, live)
+	for _, item := range drift {
+		slog.Warn("Module does not match the registry.", "type", softwareType, "drift", item.Type, "name", item.Name, "expected", item.Expected, "actual", item.Actual)
+	}
+	if !registry.Changed(drift) {
+		return r, drift
+	}
+	if err := r.Save(); err != nil {
+		slog.Warn("Could not save registry.", "path", GetRegistryPath(), "err", err)
+	}
+	return r, drift
+}
+
+// driftCmd represents the drift command
+func NewDriftCommand(softwareType string, live func(ctx context.Context) ([]registry.LiveModule, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   "drift",
+		Short: "Compare the installed modules with the modules installed by the plugin",
+		Long: `Compare the installed modules with the modules installed by the plugin.
+
+Each difference is printed in the form of:
+	<missing|unmanaged|version|nodes>	<MODULE_NAME>	<EXPECTED>	<ACTUAL>
+
+The command fails if any differences are found.
+`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			slog.Debug("Executing", "cmd", cmd.CalledAs(), "args", args)
+
+			modules, err := live(cmd.Context())
+			if err != nil {
+				return err
+			}
+			_, drift := ReconcileRegistry(softwareType, modules)
+			for _, item := range drift {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\t%s\n", item.Type, item.Name, item.Expected, item.Actual)
+			}
+			if len(drift) > 0 {
+				return fmt.Errorf("%w. type=%s, count=%d", registry.ErrDrift, softwareType, len(drift))
+			}
+			return nil
+		},
+	}
+}
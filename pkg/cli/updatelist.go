@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ActionInstall = "install"
+	ActionRemove  = "remove"
+)
+
+// UpdateListCommand is the name of the plugin command which applies a list of install and remove actions
+const UpdateListCommand = "update-list"
+
+// UpdateAction is a single line of the thin-edge.io update-list input
+type UpdateAction struct {
+	Action  string
+	Name    string
+	Version string
+	Path    string
+}
+
+// ParseUpdateList reads the update-list actions which are given by thin-edge.io via stdin.
+// Each line is tab separated in the form of: <action>\t<name>\t<version>\t<path>
+// Docs: https://thin-edge.github.io/thin-edge.io/references/software-management-plugin-api/
+func ParseUpdateList(r io.Reader) ([]UpdateAction, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = '\t'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	actions := make([]UpdateAction, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("invalid update-list line. expected at least 2 fields. line=%s", strings.Join(record, "\t"))
+		}
+
+		action := UpdateAction{
+			Action: strings.TrimSpace(record[0]),
+			Name:   record[1],
+		}
+		if len(record) > 2 {
+			action.Version = record[2]
+		}
+		if len(record) > 3 {
+			action.Path = record[3]
+		}
+
+		switch action.Action {
+		case ActionInstall, ActionRemove:
+		default:
+			return nil, fmt.Errorf("unsupported update-list action. action=%s", action.Action)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
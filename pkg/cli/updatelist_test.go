@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseUpdateList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []UpdateAction
+		wantErr bool
+	}{
+		{
+			name:  "install and remove",
+			input: "install\tflow1\t1.0.0\t/tmp/flow1.json\nremove\tflow2\t2.0.0\n",
+			want: []UpdateAction{
+				{Action: ActionInstall, Name: "flow1", Version: "1.0.0", Path: "/tmp/flow1.json"},
+				{Action: ActionRemove, Name: "flow2", Version: "2.0.0"},
+			},
+		},
+		{
+			name:  "empty fields",
+			input: "install\tflow1\t\t/tmp/flow1.json\nremove\tflow2\n",
+			want: []UpdateAction{
+				{Action: ActionInstall, Name: "flow1", Path: "/tmp/flow1.json"},
+				{Action: ActionRemove, Name: "flow2"},
+			},
+		},
+		{
+			name:  "spaces and quotes are kept",
+			input: "install\tmy \"flow\"\t1.0.0\t/tmp/my flow.json\n",
+			want: []UpdateAction{
+				{Action: ActionInstall, Name: "my \"flow\"", Version: "1.0.0", Path: "/tmp/my flow.json"},
+			},
+		},
+		{
+			name:  "empty lines are ignored",
+			input: "\ninstall\tflow1\t1.0.0\n\n",
+			want:  []UpdateAction{{Action: ActionInstall, Name: "flow1", Version: "1.0.0"}},
+		},
+		{
+			name:  "empty input",
+			input: "",
+			want:  []UpdateAction{},
+		},
+		{
+			name:    "missing name",
+			input:   "install\n",
+			wantErr: true,
+		},
+		{
+			name:    "space separated",
+			input:   "install flow1 1.0.0\n",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported action",
+			input:   "upgrade\tflow1\t1.0.0\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed line after a valid line",
+			input:   "install\tflow1\t1.0.0\nremove\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUpdateList(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseUpdateList() expected an error. got=%v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseUpdateList() unexpected error. %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseUpdateList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
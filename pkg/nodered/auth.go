@@ -0,0 +1,317 @@
+package nodered
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/utils"
+)
+
+var ErrNoCredentials = fmt.Errorf("%w. no credentials configured", ErrUnauthorized)
+
+// Token is an access token issued by the node-red admin api
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int64     `json:"expires_in,omitempty"`
+	TokenType   string    `json:"token_type,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+
+	// Used to detect if a cached token belongs to different settings
+	BaseURL  string `json:"base_url,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func (t *Token) Expired() bool {
+	if t.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().Add(time.Minute).After(t.ExpiresAt)
+}
+
+// authTransport adds the Authorization header to each request. The header is added below
+// the resty client so that the token is never included in the resty debug logs.
+// If a 401 response is received, a new token is requested (using the password grant) and
+// the request is sent again
+type authTransport struct {
+	base http.RoundTripper
+
+	mu          sync.Mutex
+	baseURL     string
+	username    string
+	password    string
+	staticToken string
+	tokenFile   string
+	token       *Token
+}
+
+func (a *authTransport) enabled() bool {
+	return a.staticToken != "" || a.username != ""
+}
+
+func (a *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !a.enabled() {
+		return a.base.RoundTrip(req)
+	}
+
+	token, err := a.getToken(req.Context(), false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.base.RoundTrip(withToken(req, token.AccessToken))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || a.staticToken != "" {
+		return resp, err
+	}
+
+	// Retry once with a new token
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	slog.Info("Access token was rejected, requesting a new one.")
+	token, err = a.getToken(req.Context(), true)
+	if err != nil {
+		return nil, err
+	}
+	retryReq := withToken(req, token.AccessToken)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retryReq.Body = body
+	}
+	return a.base.RoundTrip(retryReq)
+}
+
+func withToken(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func (a *authTransport) getToken(ctx context.Context, refresh bool) (*Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.staticToken != "" {
+		return &Token{AccessToken: a.staticToken}, nil
+	}
+	if !refresh {
+		if a.token == nil {
+			a.token = a.readCachedToken()
+		}
+		if a.token != nil && !a.token.Expired() {
+			return a.token, nil
+		}
+	}
+
+	token, err := a.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.token = token
+	a.writeCachedToken(token)
+	return token, nil
+}
+
+// login requests a new access token using the password grant
+// Docs: https://nodered.org/docs/api/admin/oauth
+func (a *authTransport) login(ctx context.Context) (*Token, error) {
+	if a.username == "" {
+		return nil, ErrNoCredentials
+	}
+	form := url.Values{}
+	form.Set("client_id", "node-red-admin")
+	form.Set("grant_type", "password")
+	form.Set("scope", "*")
+	form.Set("username", a.username)
+	form.Set("password", a.password)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.baseURL, "/")+"/auth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Transport: a.base}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		serverErr := NewServerError(resp.StatusCode, body)
+		if resp.StatusCode < http.StatusInternalServerError {
+			// The credentials were rejected
+			serverErr.Err = ErrUnauthorized
+		}
+		return nil, fmt.Errorf("login failed. %w", serverErr)
+	}
+
+	token := &Token{}
+	if err := json.NewDecoder(resp.Body).Decode(token); err != nil {
+		return nil, err
+	}
+	if token.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	token.BaseURL = a.baseURL
+	token.Username = a.username
+	slog.Info("Received new access token.", "username", a.username, "expires", token.ExpiresAt)
+	return token, nil
+}
+
+func (a *authTransport) readCachedToken() *Token {
+	if a.tokenFile == "" {
+		return nil
+	}
+	b, err := os.ReadFile(a.tokenFile)
+	if err != nil {
+		return nil
+	}
+	token := &Token{}
+	if err := json.Unmarshal(b, token); err != nil {
+		slog.Warn("Ignoring invalid token cache.", "path", a.tokenFile, "err", err)
+		return nil
+	}
+	if token.BaseURL != a.baseURL || token.Username != a.username {
+		return nil
+	}
+	return token
+}
+
+func (a *authTransport) writeCachedToken(token *Token) {
+	if a.tokenFile == "" {
+		return
+	}
+	b, err := json.Marshal(token)
+	if err != nil {
+		return
+	}
+	if err := utils.WriteFileAtomic(a.tokenFile, b, 0600); err != nil {
+		slog.Warn("Could not cache access token.", "path", a.tokenFile, "err", err)
+	}
+}
+
+func (a *authTransport) removeCachedToken() error {
+	a.token = nil
+	if a.tokenFile == "" {
+		return nil
+	}
+	if err := os.Remove(a.tokenFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SetCredentials sets the username and password used to request access tokens
+func (c *Client) SetCredentials(username string, password string) *Client {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	c.auth.username = username
+	c.auth.password = password
+	return c
+}
+
+// SetToken sets a static access token which is used instead of requesting one
+func (c *Client) SetToken(token string) *Client {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	c.auth.staticToken = token
+	return c
+}
+
+// SetTokenCacheFile sets the file used to cache access tokens between calls
+func (c *Client) SetTokenCacheFile(path string) *Client {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	c.auth.tokenFile = path
+	return c
+}
+
+// Login requests a new access token and caches it
+func (c *Client) Login() (*Token, error) {
+	return c.LoginContext(c.context())
+}
+
+// LoginContext is the same as Login, but uses the given context
+func (c *Client) LoginContext(ctx context.Context) (*Token, error) {
+	return c.auth.getToken(ctx, true)
+}
+
+// Logout revokes the current access token and removes it from the cache
+// Docs: https://nodered.org/docs/api/admin/methods/post/auth/revoke/
+func (c *Client) Logout() error {
+	return c.LogoutContext(c.context())
+}
+
+// LogoutContext is the same as Logout, but uses the given context
+func (c *Client) LogoutContext(ctx context.Context) error {
+	c.auth.mu.Lock()
+	if c.auth.token == nil {
+		c.auth.token = c.auth.readCachedToken()
+	}
+	token := c.auth.token
+	c.auth.mu.Unlock()
+
+	if token == nil {
+		slog.Info("No access token to revoke.")
+		return nil
+	}
+
+	if token.Expired() {
+		slog.Info("Access token has already expired, so it is not revoked.", "expires", token.ExpiresAt)
+	} else if err := c.auth.revoke(ctx, token); err != nil {
+		return err
+	}
+
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	return c.auth.removeCachedToken()
+}
+
+// revoke revokes the given access token. The request is sent directly (rather than via the
+// auth transport) so that a rejected token does not result in requesting a new one
+func (a *authTransport) revoke(ctx context.Context, token *Token) error {
+	body, err := json.Marshal(map[string]string{"token": token.AccessToken})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.baseURL, "/")+"/auth/revoke", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	client := &http.Client{Transport: a.base}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		slog.Info("Access token is no longer valid.")
+	case resp.StatusCode >= http.StatusBadRequest:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("logout failed. %w", NewServerError(resp.StatusCode, body))
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package nodered
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authServer is a node-red admin api which only accepts the last issued access token
+type authServer struct {
+	mu       sync.Mutex
+	logins   int
+	valid    string
+	revoked  []string
+	requests []string
+}
+
+func (s *authServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.URL.Path {
+	case "/auth/token":
+		form, _ := url.ParseQuery(string(body))
+		if form.Get("username") != "admin" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		s.logins++
+		s.valid = "token-" + strings.Repeat("x", s.logins)
+		json.NewEncoder(w).Encode(map[string]any{"access_token": s.valid, "expires_in": 3600, "token_type": "Bearer"})
+	case "/auth/revoke":
+		if r.Header.Get("Authorization") != "Bearer "+s.valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		payload := map[string]string{}
+		json.Unmarshal(body, &payload)
+		s.revoked = append(s.revoked, payload["token"])
+		s.valid = ""
+	default:
+		if s.valid == "" || r.Header.Get("Authorization") != "Bearer "+s.valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path+" "+string(body))
+		json.NewEncoder(w).Encode(map[string]any{"rev": "2"})
+	}
+}
+
+func newAuthClient(t *testing.T, server *httptest.Server, cached *Token) (*Client, string) {
+	t.Helper()
+	tokenFile := filepath.Join(t.TempDir(), "token.json")
+	if cached != nil {
+		cached.BaseURL = server.URL
+		cached.Username = "admin"
+		b, err := json.Marshal(cached)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(tokenFile, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := NewClientWithoutRetries(server.URL).
+		SetCredentials("admin", "secret").
+		SetTokenCacheFile(tokenFile)
+	return client, tokenFile
+}
+
+func readCachedToken(t *testing.T, path string) *Token {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := &Token{}
+	if err := json.Unmarshal(b, token); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthRefreshesRejectedToken(t *testing.T) {
+	api := &authServer{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client, tokenFile := newAuthClient(t, server, &Token{AccessToken: "stale", ExpiresAt: time.Now().Add(time.Hour)})
+	resp, err := client.SetFlow("1", []Node{{"id": "t1", "type": "tab"}})
+	if err != nil {
+		t.Fatalf("SetFlow() unexpected error. %v", err)
+	}
+	if resp.Rev != "2" {
+		t.Errorf("SetFlow() rev = %s, want 2", resp.Rev)
+	}
+	if api.logins != 1 {
+		t.Errorf("logins = %d, want 1", api.logins)
+	}
+	if len(api.requests) != 1 || !strings.Contains(api.requests[0], `"id":"t1"`) {
+		t.Errorf("requests = %v, want the replayed request with its body", api.requests)
+	}
+	if token := readCachedToken(t, tokenFile); token == nil || token.AccessToken != api.valid {
+		t.Errorf("cached token = %v, want %s", token, api.valid)
+	}
+
+	// The new token is reused
+	if _, err := client.GetFlowDocument(); err != nil {
+		t.Fatalf("GetFlowDocument() unexpected error. %v", err)
+	}
+	if api.logins != 1 {
+		t.Errorf("logins = %d, want 1", api.logins)
+	}
+}
+
+func TestAuthLogsInWhenCachedTokenExpired(t *testing.T) {
+	api := &authServer{valid: "expired"}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client, _ := newAuthClient(t, server, &Token{AccessToken: "expired", ExpiresAt: time.Now().Add(-time.Minute)})
+	if _, err := client.GetFlowDocument(); err != nil {
+		t.Fatalf("GetFlowDocument() unexpected error. %v", err)
+	}
+	if api.logins != 1 {
+		t.Errorf("logins = %d, want 1", api.logins)
+	}
+}
+
+func TestAuthRejectedCredentials(t *testing.T) {
+	server := httptest.NewServer(&authServer{})
+	defer server.Close()
+
+	client, _ := newAuthClient(t, server, nil)
+	client.SetCredentials("unknown", "secret")
+	if _, err := client.GetFlowDocument(); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("GetFlowDocument() error = %v, want %v", err, ErrUnauthorized)
+	}
+}
+
+func TestAuthStaticTokenIsNotRefreshed(t *testing.T) {
+	api := &authServer{valid: "valid"}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client, _ := newAuthClient(t, server, nil)
+	client.SetToken("invalid")
+	if _, err := client.GetFlowDocument(); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("GetFlowDocument() error = %v, want %v", err, ErrUnauthorized)
+	}
+	if api.logins != 0 {
+		t.Errorf("logins = %d, want 0", api.logins)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name        string
+		valid       string
+		cached      *Token
+		wantRevoked []string
+	}{
+		{
+			name:        "revoke cached token",
+			valid:       "cached",
+			cached:      &Token{AccessToken: "cached", ExpiresAt: time.Now().Add(time.Hour)},
+			wantRevoked: []string{"cached"},
+		},
+		{
+			name:   "expired token is not revoked",
+			valid:  "cached",
+			cached: &Token{AccessToken: "cached", ExpiresAt: time.Now().Add(-time.Minute)},
+		},
+		{
+			name:   "rejected token is not refreshed",
+			valid:  "other",
+			cached: &Token{AccessToken: "cached", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		{
+			name: "no cached token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &authServer{valid: tt.valid}
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			client, tokenFile := newAuthClient(t, server, tt.cached)
+			if err := client.Logout(); err != nil {
+				t.Fatalf("Logout() unexpected error. %v", err)
+			}
+			if api.logins != 0 {
+				t.Errorf("logins = %d, want 0", api.logins)
+			}
+			if strings.Join(api.revoked, ",") != strings.Join(tt.wantRevoked, ",") {
+				t.Errorf("revoked = %v, want %v", api.revoked, tt.wantRevoked)
+			}
+			if token := readCachedToken(t, tokenFile); token != nil {
+				t.Errorf("cached token = %v, want it to be removed", token)
+			}
+		})
+	}
+}
@@ -1,47 +1,30 @@
 package nodered
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"log/slog"
+	"net"
 	"net/http"
-	"time"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/tidwall/gjson"
 
 	"github.com/go-resty/resty/v2"
 )
 
-type BadRequestError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-}
-
-func (e *BadRequestError) Error() string {
-	return fmt.Sprintf("%s. %s", e.Code, e.Message)
-}
-
-var ErrNotFound = errors.New("resource not found")
-
-type ServerError struct {
-	Err error
-}
-
-func (e *ServerError) Error() string {
-	return e.Err.Error()
-}
-
-func (e *ServerError) Unwrap() error {
-	return e.Err
-}
-
 type FlowResponseV2 struct {
 	Flows       any    `json:"flows"`
 	Rev         string `json:"rev,omitempty"`
 	Credentials any    `json:"credentials,omitempty"`
 }
 
+type FlowDocument struct {
+	Flows []Node `json:"flows"`
+	Rev   string `json:"rev,omitempty"`
+}
+
 type FlowEnv struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
@@ -80,8 +63,12 @@ func (f Flow) GetVersion() string {
 }
 
 type Client struct {
-	api     *resty.Client
-	BaseURL string
+	api         *resty.Client
+	transport   *http.Transport
+	dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	auth        *authTransport
+	ctx         context.Context
+	BaseURL     string
 }
 
 func IsTab(v string) bool {
@@ -97,63 +84,54 @@ func NewClientWithoutRetries(baseURL string) *Client {
 }
 
 func NewClient(baseURL string, maxRetries int) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	c := &Client{
-		api: resty.NewWithClient(http.DefaultClient),
+		transport:   transport,
+		dialContext: transport.DialContext,
+		auth: &authTransport{
+			base: transport,
+		},
 	}
+	c.api = resty.NewWithClient(&http.Client{Transport: c.auth})
 
 	// Configure retries for more resilient behaviour
-	c.api.SetRetryCount(maxRetries).
-		SetRetryWaitTime(10 * time.Second).
-		SetRetryMaxWaitTime(60 * time.Second).
-		AddRetryCondition(
-			func(r *resty.Response, err error) bool {
-				if err != nil {
-					slog.Info("Retry on error.", "err", err)
-					return true
-				}
-				if (r.StatusCode() == http.StatusBadGateway) || (r.StatusCode() == http.StatusGatewayTimeout) {
-					slog.Info("Retry on HTTP error.", "status", r.Status())
-					return true
-				}
-				return false
-			},
-		)
+	policy := DefaultRetryPolicy()
+	policy.Count = maxRetries
+	c.SetRetryPolicy(policy)
 
 	c.api.Debug = false
 	c.api.EnableTrace()
+	c.api.OnRequestLog(redactRequestLog)
+	c.api.OnResponseLog(redactResponseLog)
 	c.api.OnAfterResponse(func(c *resty.Client, r *resty.Response) error {
 		if r.StatusCode() > 399 || r.StatusCode() < 200 {
-			var err error
-			switch r.StatusCode() {
-			case http.StatusNotFound:
-				err = ErrNotFound
-			case http.StatusBadRequest:
-				badRequestErr := &BadRequestError{}
-				if marshalErr := json.Unmarshal(r.Body(), &badRequestErr); marshalErr == nil {
-					err = badRequestErr
-				} else {
-					err = fmt.Errorf("invalid request. %w", err)
-				}
-
-			default:
-				err = fmt.Errorf("api error")
-			}
-			return &ServerError{
-				Err: err,
-			}
+			return NewServerError(r.StatusCode(), r.Body())
 		}
 		return nil
 	})
 	c.api.
-		SetBaseURL(baseURL).
 		SetHeader("Node-RED-API-Version", "v2").
 		SetHeader("Content-Type", "application/json")
 
-	return c
+	return c.SetBaseURL(baseURL)
 }
 
+// SetBaseURL sets the url of the node-red admin api. A unix domain socket can be
+// used by using the unix scheme, e.g. unix:///run/nodered/admin.sock
 func (c *Client) SetBaseURL(u string) *Client {
+	c.BaseURL = u
+	if socket, ok := strings.CutPrefix(u, "unix://"); ok {
+		c.transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		// The host is not used, as all connections are made via the socket
+		u = "http://localhost"
+	} else {
+		c.transport.DialContext = c.dialContext
+	}
 	c.api.SetBaseURL(u)
+	c.auth.baseURL = u
 	return c
 }
 
@@ -162,12 +140,36 @@ func (c *Client) SetDebug(v bool) *Client {
 	return c
 }
 
+// SetContext sets the context which is used by the methods which don't accept a context,
+// e.g. so that all requests of an operation are cancelled when the operation is cancelled
+func (c *Client) SetContext(ctx context.Context) *Client {
+	c.ctx = ctx
+	return c
+}
+
+// Context returns the context which is used by the methods which don't accept a context
+func (c *Client) Context() context.Context {
+	return c.context()
+}
+
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 //
 // Flows
 //
 
 func (c *Client) GetFlows() ([]Flow, error) {
-	resp, err := c.api.R().Get("flows")
+	return c.GetFlowsContext(c.context())
+}
+
+// GetFlowsContext is the same as GetFlows, but uses the given context
+func (c *Client) GetFlowsContext(ctx context.Context) ([]Flow, error) {
+	resp, err := c.api.R().SetContext(ctx).Get("flows")
 	if err != nil {
 		return nil, err
 	}
@@ -190,24 +192,55 @@ func (c *Client) GetFlows() ([]Flow, error) {
 	return flows, nil
 }
 
+// Get all of the deployed nodes along with the current revision
+// Docs: https://nodered.org/docs/api/admin/methods/get/flows/
+func (c *Client) GetFlowDocument() (*FlowDocument, error) {
+	return c.GetFlowDocumentContext(c.context())
+}
+
+// GetFlowDocumentContext is the same as GetFlowDocument, but uses the given context
+func (c *Client) GetFlowDocumentContext(ctx context.Context) (*FlowDocument, error) {
+	data := &FlowDocument{}
+	_, err := c.api.R().SetContext(ctx).SetResult(data).Get("flows")
+	if err != nil {
+		return nil, err
+	}
+	if data.Flows == nil {
+		data.Flows = make([]Node, 0)
+	}
+	return data, nil
+}
+
 // Set new flows
 // Docs: https://nodered.org/docs/api/admin/methods/post/flows/
 func (c *Client) SetFlow(rev string, flowIn any) (*FlowResponseV2, error) {
+	return c.SetFlowContext(c.context(), rev, flowIn)
+}
+
+// SetFlowContext is the same as SetFlow, but uses the given context
+func (c *Client) SetFlowContext(ctx context.Context, rev string, flowIn any) (*FlowResponseV2, error) {
+	return c.SetFlowWithCredentialsContext(ctx, rev, flowIn, nil)
+}
+
+// Set new flows along with the credentials of all of the nodes, e.g. the encrypted
+// credentials ({"$": "..."}) of a flows_cred.json file. The existing credentials are replaced.
+// Credentials of individual nodes can also be set via the "credentials" property of the node
+// Docs: https://nodered.org/docs/api/admin/methods/post/flows/
+func (c *Client) SetFlowWithCredentials(rev string, flowIn any, credentials any) (*FlowResponseV2, error) {
+	return c.SetFlowWithCredentialsContext(c.context(), rev, flowIn, credentials)
+}
+
+// SetFlowWithCredentialsContext is the same as SetFlowWithCredentials, but uses the given context
+func (c *Client) SetFlowWithCredentialsContext(ctx context.Context, rev string, flowIn any, credentials any) (*FlowResponseV2, error) {
 	requestBody := &FlowResponseV2{
-		Flows: flowIn,
-		Rev:   rev,
+		Flows:       flowIn,
+		Rev:         rev,
+		Credentials: credentials,
 	}
 
-	// Replace variables
-	// TODO: Does this make sense?
-	// host.containers.internal
-	// host.docker.internal
-	// tedge
-	// body = bytes.ReplaceAll(body, []byte("${TEDGE_MQTT_HOST}"), []byte("host.docker.internal"))
-	// body = bytes.ReplaceAll(body, []byte("${TEDGE_MQTT_PORT}"), []byte("1883"))
-
+	// Deploying the same flows again has no additional effect, so the request can be retried
 	data := &FlowResponseV2{}
-	_, err := c.api.R().
+	_, err := c.api.R().SetContext(idempotent(ctx)).
 		SetHeader("Node-RED-Deployment-Type", "full").
 		SetResult(&data).
 		SetBody(requestBody).
@@ -219,7 +252,12 @@ func (c *Client) SetFlow(rev string, flowIn any) (*FlowResponseV2, error) {
 // Delete an existing flow
 // Docs: https://nodered.org/docs/api/admin/methods/delete/flow/
 func (c *Client) DeleteFlow(flowID string) error {
-	_, err := c.api.R().Delete("flow/" + flowID)
+	return c.DeleteFlowContext(c.context(), flowID)
+}
+
+// DeleteFlowContext is the same as DeleteFlow, but uses the given context
+func (c *Client) DeleteFlowContext(ctx context.Context, flowID string) error {
+	_, err := c.api.R().SetContext(ctx).Delete("flow/" + flowID)
 	return err
 }
 
@@ -227,15 +265,67 @@ func (c *Client) DeleteFlow(flowID string) error {
 // Projects
 //
 
+type FileStatus struct {
+	Type   string `json:"type,omitempty"`
+	Index  string `json:"index,omitempty"`
+	Local  string `json:"local,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// IsUntracked checks if the file is not tracked by git
+func (f FileStatus) IsUntracked() bool {
+	return f.Status == "??"
+}
+
 type ProjectStatus struct {
-	Files    map[string]any    `json:"files,omitempty"`
-	Commits  map[string]any    `json:"commits,omitempty"`
-	Branches map[string]string `json:"branches,omitempty"`
+	Files    map[string]FileStatus `json:"files,omitempty"`
+	Commits  BranchStatus          `json:"commits"`
+	Branches map[string]string     `json:"branches,omitempty"`
+	Merging  bool                  `json:"merging,omitempty"`
+}
+
+// ChangedFiles returns the sorted list of tracked files which have local changes
+func (s *ProjectStatus) ChangedFiles() []string {
+	files := make([]string, 0)
+	for name, file := range s.Files {
+		if !file.IsUntracked() {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// LocalBranch returns the checked out branch
+func (s *ProjectStatus) LocalBranch() string {
+	return s.Branches["local"]
+}
+
+// RemoteBranch returns the remote branch which is tracked by the checked out branch
+func (s *ProjectStatus) RemoteBranch() string {
+	return s.Branches["remote"]
 }
 
 type Commit struct {
-	Sha     string `json:"sha,omitempty"`
-	Subject string `json:"subject,omitempty"`
+	Sha     string   `json:"sha,omitempty"`
+	Subject string   `json:"subject,omitempty"`
+	Refs    []string `json:"refs,omitempty"`
+}
+
+// Tags returns the tags which point to the commit
+func (c *Commit) Tags() []string {
+	tags := make([]string, 0)
+	for _, ref := range c.Refs {
+		if tag, ok := strings.CutPrefix(strings.TrimSpace(ref), "tag: "); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+type Commits struct {
+	Count   int64    `json:"count"`
+	Commits []Commit `json:"commits"`
 }
 
 type BranchStatus struct {
@@ -262,8 +352,15 @@ type ProjectsResponse struct {
 
 type Repository struct {
 	URL      string `json:"url,omitempty"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Name of a ssh key which has been added to node-red
+	KeyFile    string `json:"keyFile,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+
+	// Branch or tag to check out when cloning the repository
+	Branch string `json:"branch,omitempty"`
 }
 
 type GitConfig struct {
@@ -276,49 +373,105 @@ type Project struct {
 	Version string `json:"version,omitempty"`
 
 	Git               *GitConfig `json:"git,omitempty"`
-	CredentialsSecret string     `json:"credentialSecret"`
+	CredentialsSecret string     `json:"credentialSecret,omitempty"`
 }
 
 func (c *Client) ProjectList() (*ProjectsResponse, error) {
+	return c.ProjectListContext(c.context())
+}
+
+// ProjectListContext is the same as ProjectList, but uses the given context
+func (c *Client) ProjectListContext(ctx context.Context) (*ProjectsResponse, error) {
 	data := &ProjectsResponse{}
-	_, err := c.api.R().SetResult(data).Get("projects")
+	_, err := c.api.R().SetContext(ctx).SetResult(data).Get("projects")
 	return data, err
 }
 
 func (c *Client) ProjectGet(name string) (*Project, error) {
+	return c.ProjectGetContext(c.context(), name)
+}
+
+// ProjectGetContext is the same as ProjectGet, but uses the given context
+func (c *Client) ProjectGetContext(ctx context.Context, name string) (*Project, error) {
 	data := &Project{}
-	_, err := c.api.R().SetResult(data).Get("projects/" + name)
+	_, err := c.api.R().SetContext(ctx).SetResult(data).Get("projects/" + name)
 	return data, err
 }
 
 func (c *Client) ProjectDelete(name string) error {
-	_, err := c.api.R().Delete("projects/" + name)
+	return c.ProjectDeleteContext(c.context(), name)
+}
+
+// ProjectDeleteContext is the same as ProjectDelete, but uses the given context
+func (c *Client) ProjectDeleteContext(ctx context.Context, name string) error {
+	_, err := c.api.R().SetContext(ctx).Delete("projects/" + name)
 	return err
 }
 
-func (c *Client) ProjectClone(name string, url string) (*Project, error) {
+// Clone a project from a remote repository. The credentials of the remote are optional, and the
+// credential secret is used to encrypt the credentials of the project's flows
+func (c *Client) ProjectClone(name string, remote Repository, credentialSecret string) (*Project, error) {
+	return c.ProjectCloneContext(c.context(), name, remote, credentialSecret)
+}
+
+// ProjectCloneContext is the same as ProjectClone, but uses the given context
+func (c *Client) ProjectCloneContext(ctx context.Context, name string, remote Repository, credentialSecret string) (*Project, error) {
 	project := Project{
 		Name: name,
 		Git: &GitConfig{
 			Remotes: map[string]Repository{
-				"origin": {
-					URL: url,
-				},
+				"origin": remote,
 			},
 		},
+		CredentialsSecret: credentialSecret,
 	}
 
 	data := &Project{}
-	_, err := c.api.R().
+	_, err := c.api.R().SetContext(ctx).
 		SetBody(project).
 		SetResult(data).
 		Post("projects")
 	return data, err
 }
 
+// Set the credentials which are used to access the origin remote of the active project.
+// node-red only keeps the credentials in memory, so they need to be set again after node-red is restarted
+func (c *Client) ProjectSetRemoteCredentials(name string, remote Repository) error {
+	return c.ProjectSetRemoteCredentialsContext(c.context(), name, remote)
+}
+
+// ProjectSetRemoteCredentialsContext is the same as ProjectSetRemoteCredentials, but uses the given context
+func (c *Client) ProjectSetRemoteCredentialsContext(ctx context.Context, name string, remote Repository) error {
+	// node-red requires both of the properties to be present
+	origin := map[string]string{}
+	if remote.KeyFile != "" {
+		origin["keyFile"] = remote.KeyFile
+		origin["passphrase"] = remote.Passphrase
+	} else {
+		origin["username"] = remote.Username
+		origin["password"] = remote.Password
+	}
+	_, err := c.api.R().SetContext(ctx).
+		SetBody(map[string]any{
+			"git": map[string]any{
+				"remotes": map[string]any{
+					"origin": origin,
+				},
+			},
+		}).
+		Put("projects/" + name)
+	return err
+}
+
 func (c *Client) ProjectPull(name string) (*Project, error) {
+	return c.ProjectPullContext(c.context(), name)
+}
+
+// ProjectPullContext is the same as ProjectPull, but uses the given context
+func (c *Client) ProjectPullContext(ctx context.Context, name string) (*Project, error) {
+	// Pulling again has no additional effect if the project is already up to date
 	data := &Project{}
-	_, err := c.api.R().
+	_, err := c.api.R().SetContext(idempotent(ctx)).
 		SetBody("{}").
 		SetResult(data).
 		Post("projects/" + name + "/pull")
@@ -326,16 +479,26 @@ func (c *Client) ProjectPull(name string) (*Project, error) {
 }
 
 func (c *Client) ProjectStatus(name string, clearContext bool) (*ProjectStatus, error) {
+	return c.ProjectStatusContext(c.context(), name, clearContext)
+}
+
+// ProjectStatusContext is the same as ProjectStatus, but uses the given context
+func (c *Client) ProjectStatusContext(ctx context.Context, name string, clearContext bool) (*ProjectStatus, error) {
 	data := &ProjectStatus{}
-	_, err := c.api.R().
+	_, err := c.api.R().SetContext(ctx).
 		SetResult(data).
 		Get("projects/" + name + "/status")
 	return data, err
 }
 
 func (c *Client) ProjectSetActive(name string, clearContext bool) (*Project, error) {
+	return c.ProjectSetActiveContext(c.context(), name, clearContext)
+}
+
+// ProjectSetActiveContext is the same as ProjectSetActive, but uses the given context
+func (c *Client) ProjectSetActiveContext(ctx context.Context, name string, clearContext bool) (*Project, error) {
 	data := &Project{}
-	_, err := c.api.R().
+	_, err := c.api.R().SetContext(ctx).
 		SetBody(map[string]any{
 			"active":       true,
 			"clearContext": clearContext,
@@ -345,8 +508,113 @@ func (c *Client) ProjectSetActive(name string, clearContext bool) (*Project, err
 	return data, err
 }
 
+// Deactivate the active project, so that no project is active
+func (c *Client) ProjectDeactivate(name string) error {
+	return c.ProjectDeactivateContext(c.context(), name)
+}
+
+// ProjectDeactivateContext is the same as ProjectDeactivate, but uses the given context
+func (c *Client) ProjectDeactivateContext(ctx context.Context, name string) error {
+	_, err := c.api.R().SetContext(ctx).
+		SetBody(map[string]any{
+			"active": false,
+		}).
+		Put("projects/" + name)
+	return err
+}
+
 func (c *Client) ProjectBranches(name string) (*Branches, error) {
+	return c.ProjectBranchesContext(c.context(), name)
+}
+
+// ProjectBranchesContext is the same as ProjectBranches, but uses the given context
+func (c *Client) ProjectBranchesContext(ctx context.Context, name string) (*Branches, error) {
 	data := &Branches{}
-	_, err := c.api.R().SetResult(data).Get("projects/" + name + "/branches")
+	_, err := c.api.R().SetContext(ctx).SetResult(data).Get("projects/" + name + "/branches")
+	return data, err
+}
+
+// Fetch the changes from the remote of the active project, without changing the checked out branch
+func (c *Client) ProjectFetch(name string) (*ProjectStatus, error) {
+	return c.ProjectFetchContext(c.context(), name)
+}
+
+// ProjectFetchContext is the same as ProjectFetch, but uses the given context
+func (c *Client) ProjectFetchContext(ctx context.Context, name string) (*ProjectStatus, error) {
+	data := &ProjectStatus{}
+	_, err := c.api.R().SetContext(ctx).
+		SetQueryParam("remote", "true").
+		SetResult(data).
+		Get("projects/" + name + "/status")
+	return data, err
+}
+
+// Discard the local changes of the given files of the active project
+func (c *Client) ProjectDiscard(name string, files []string) error {
+	return c.ProjectDiscardContext(c.context(), name, files)
+}
+
+// ProjectDiscardContext is the same as ProjectDiscard, but uses the given context
+func (c *Client) ProjectDiscardContext(ctx context.Context, name string, files []string) error {
+	_, err := c.api.R().SetContext(idempotent(ctx)).
+		SetBody(map[string]any{
+			"files": files,
+		}).
+		Post("projects/" + name + "/discard")
+	return err
+}
+
+// Unstage all of the staged files of the active project
+func (c *Client) ProjectUnstageAll(name string) error {
+	return c.ProjectUnstageAllContext(c.context(), name)
+}
+
+// ProjectUnstageAllContext is the same as ProjectUnstageAll, but uses the given context
+func (c *Client) ProjectUnstageAllContext(ctx context.Context, name string) error {
+	_, err := c.api.R().SetContext(ctx).Delete("projects/" + name + "/stage")
+	return err
+}
+
+// Abort an in-progress merge of the active project
+func (c *Client) ProjectAbortMerge(name string) error {
+	return c.ProjectAbortMergeContext(c.context(), name)
+}
+
+// ProjectAbortMergeContext is the same as ProjectAbortMerge, but uses the given context
+func (c *Client) ProjectAbortMergeContext(ctx context.Context, name string) error {
+	_, err := c.api.R().SetContext(ctx).Delete("projects/" + name + "/merge")
+	return err
+}
+
+// Check out a branch, tag or commit of the active project. Checking out
+// a tag or commit results in a detached HEAD
+func (c *Client) ProjectCheckout(name string, ref string) (*Project, error) {
+	return c.ProjectCheckoutContext(c.context(), name, ref)
+}
+
+// ProjectCheckoutContext is the same as ProjectCheckout, but uses the given context
+func (c *Client) ProjectCheckoutContext(ctx context.Context, name string, ref string) (*Project, error) {
+	data := &Project{}
+	_, err := c.api.R().SetContext(idempotent(ctx)).
+		SetBody(map[string]any{
+			"name": ref,
+		}).
+		SetResult(data).
+		Post("projects/" + name + "/branches")
+	return data, err
+}
+
+// Get the latest commits of the checked out branch of the active project
+func (c *Client) ProjectCommits(name string, limit int) (*Commits, error) {
+	return c.ProjectCommitsContext(c.context(), name, limit)
+}
+
+// ProjectCommitsContext is the same as ProjectCommits, but uses the given context
+func (c *Client) ProjectCommitsContext(ctx context.Context, name string, limit int) (*Commits, error) {
+	data := &Commits{}
+	_, err := c.api.R().SetContext(ctx).
+		SetQueryParam("limit", strconv.Itoa(limit)).
+		SetResult(data).
+		Get("projects/" + name + "/commits")
 	return data, err
 }
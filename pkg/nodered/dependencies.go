@@ -0,0 +1,60 @@
+package nodered
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrMissingTypes = errors.New("flows use node types which are not installed")
+
+// Mapping of well known node types to the module which provides them
+//
+//go:embed node_types.json
+var nodeTypesJSON []byte
+
+var nodeTypeModules = func() map[string]string {
+	v := make(map[string]string)
+	if err := json.Unmarshal(nodeTypesJSON, &v); err != nil {
+		panic(err)
+	}
+	return v
+}()
+
+// LookupTypeModule returns the module which provides a well known node type
+func LookupTypeModule(nodeType string) (string, bool) {
+	v, ok := nodeTypeModules[nodeType]
+	return v, ok
+}
+
+// IsInternalType checks if a node type is handled by the runtime itself, so it is
+// not provided by any module
+func IsInternalType(nodeType string) bool {
+	switch nodeType {
+	case "tab", "subflow", "group", "junction", "global-config", "unknown":
+		return true
+	}
+	return strings.HasPrefix(nodeType, "subflow:")
+}
+
+// MissingTypes returns the sorted list of node types used by the nodes which are not registered
+func MissingTypes(nodes []Node, registered map[string]struct{}) []string {
+	missing := make(map[string]struct{})
+	for _, node := range nodes {
+		nodeType := node.Type()
+		if nodeType == "" || IsInternalType(nodeType) {
+			continue
+		}
+		if _, ok := registered[nodeType]; !ok {
+			missing[nodeType] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(missing))
+	for k := range missing {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,92 @@
+package nodered
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrNotFound     = errors.New("resource not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrConflict     = errors.New("conflict. the flows were changed by someone else (revision mismatch)")
+	ErrUnavailable  = errors.New("node-red is unavailable")
+	ErrValidation   = errors.New("validation failed")
+	ErrAPI          = errors.New("api error")
+)
+
+// BadRequestError is the body of a response which node-red rejected as invalid
+type BadRequestError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *BadRequestError) Error() string {
+	return fmt.Sprintf("%s. %s. %s", ErrValidation, e.Code, e.Message)
+}
+
+func (e *BadRequestError) Unwrap() error {
+	return ErrValidation
+}
+
+// ServerError is an error response from node-red. The status code and the
+// response body are kept so that callers can handle specific errors
+type ServerError struct {
+	StatusCode int
+	Body       []byte
+	Err        error
+}
+
+// NewServerError creates an error from the status code and body of a response
+func NewServerError(statusCode int, body []byte) *ServerError {
+	var err error
+	switch statusCode {
+	case http.StatusNotFound:
+		err = ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		err = ErrUnauthorized
+	case http.StatusConflict:
+		err = ErrConflict
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		err = ErrUnavailable
+	case http.StatusBadRequest:
+		badRequestErr := &BadRequestError{}
+		if parseErr := json.Unmarshal(body, badRequestErr); parseErr == nil && (badRequestErr.Code != "" || badRequestErr.Message != "") {
+			err = badRequestErr
+		} else {
+			err = ErrValidation
+		}
+	default:
+		err = ErrAPI
+	}
+	return &ServerError{
+		StatusCode: statusCode,
+		Body:       body,
+		Err:        err,
+	}
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s. status=%d", e.Err, e.StatusCode)
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnavailable checks if an error was caused by node-red not being available,
+// e.g. it is not running, it is starting, or a proxy could not reach it
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrNotReady) || isNotSent(err)
+}
+
+// NotApplied checks if a failed request was not applied by node-red, i.e. node-red rejected
+// it with a client error (e.g. invalid flows), or the request was never sent
+func NotApplied(err error) bool {
+	serverErr := &ServerError{}
+	if errors.As(err, &serverErr) {
+		return serverErr.StatusCode >= 400 && serverErr.StatusCode < 500
+	}
+	return isNotSent(err)
+}
@@ -0,0 +1,262 @@
+package nodered
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrModuleNotInstalled = errors.New("module is not installed")
+var ErrNodeIDConflict = errors.New("node id is already used by other flows")
+
+// Node is a single node-red node as it appears in a flows document.
+// It can be a tab, subflow, config node or a regular node
+type Node map[string]any
+
+func (n Node) GetString(key string) string {
+	if v, ok := n[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (n Node) ID() string {
+	return n.GetString("id")
+}
+
+func (n Node) Type() string {
+	return n.GetString("type")
+}
+
+// Parent returns the id of the tab or subflow which the node is placed on
+func (n Node) Parent() string {
+	return n.GetString("z")
+}
+
+func (n Node) IsTab() bool {
+	return IsTab(n.Type())
+}
+
+// GetEnv returns the value of a flow level environment variable
+func (n Node) GetEnv(name string) (string, bool) {
+	items, ok := n["env"].([]any)
+	if !ok {
+		return "", false
+	}
+	for _, item := range items {
+		if env, ok := item.(map[string]any); ok && env["name"] == name {
+			value, _ := env["value"].(string)
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// GetModuleName returns the name of the module the tab belongs to.
+// It uses the same logic as Flow.GetName
+func (n Node) GetModuleName() string {
+	if v, ok := n.GetEnv("MODULE_NAME"); ok {
+		return v
+	}
+	return n.GetString("label")
+}
+
+// GetModuleVersion returns the version of the module the tab belongs to
+func (n Node) GetModuleVersion() string {
+	v, _ := n.GetEnv("MODULE_VERSION")
+	return v
+}
+
+// IsShared checks if the node can be used by nodes on other tabs, e.g. config nodes and subflows
+func (n Node) IsShared() bool {
+	return n.Type() == "subflow" || (n.Parent() == "" && !n.IsTab())
+}
+
+// References returns the ids of other nodes which the node refers to,
+// e.g. config nodes used in its properties or the subflow it is an instance of
+func (n Node) References() map[string]struct{} {
+	refs := make(map[string]struct{})
+	if id, ok := strings.CutPrefix(n.Type(), "subflow:"); ok {
+		refs[id] = struct{}{}
+	}
+	for key, value := range n {
+		if key == "id" || key == "z" {
+			continue
+		}
+		collectStrings(value, refs)
+	}
+	return refs
+}
+
+func collectStrings(v any, out map[string]struct{}) {
+	switch value := v.(type) {
+	case string:
+		out[value] = struct{}{}
+	case []any:
+		for _, item := range value {
+			collectStrings(item, out)
+		}
+	case map[string]any:
+		for _, item := range value {
+			collectStrings(item, out)
+		}
+	}
+}
+
+// ModuleNodes returns the ids of the nodes owned by a module, which are
+// the module's tabs and all nodes placed on them
+func ModuleNodes(nodes []Node, module string) map[string]struct{} {
+	return ModuleVersionNodes(nodes, module, "")
+}
+
+// ModuleVersionNodes returns the ids of the nodes owned by a specific version of a module.
+// An empty version matches any version
+func ModuleVersionNodes(nodes []Node, module string, version string) map[string]struct{} {
+	owned := make(map[string]struct{})
+	for _, node := range nodes {
+		if !node.IsTab() || node.GetModuleName() != module {
+			continue
+		}
+		if version != "" && node.GetModuleVersion() != version {
+			continue
+		}
+		owned[node.ID()] = struct{}{}
+	}
+	for _, node := range nodes {
+		if _, ok := owned[node.Parent()]; ok {
+			owned[node.ID()] = struct{}{}
+		}
+	}
+	return owned
+}
+
+// MergeModule replaces the nodes owned by a module with the incoming nodes.
+// Nodes belonging to other modules are kept as is, and shared nodes (e.g. config nodes and
+// subflows) which are also included in the incoming nodes are updated in place.
+// An error is returned if an incoming node uses the id of a node owned by other flows
+func MergeModule(current []Node, module string, incoming []Node) ([]Node, error) {
+	owned := ModuleNodes(current, module)
+	nodes := NodeIndex(current)
+
+	merged := make([]Node, 0, len(current)+len(incoming))
+	index := make(map[string]int)
+	for _, node := range current {
+		if _, ok := owned[node.ID()]; ok {
+			continue
+		}
+		index[node.ID()] = len(merged)
+		merged = append(merged, node)
+	}
+
+	for _, node := range incoming {
+		if i, ok := index[node.ID()]; ok {
+			existing := merged[i]
+			// nodes placed inside a subflow are shared along with the subflow
+			if !existing.IsShared() && nodes[existing.Parent()].Type() != "subflow" {
+				return nil, fmt.Errorf("%w. id=%s, type=%s, flow=%s", ErrNodeIDConflict, node.ID(), existing.Type(), ownerName(nodes, existing))
+			}
+			merged[i] = node
+			continue
+		}
+		index[node.ID()] = len(merged)
+		merged = append(merged, node)
+	}
+	return merged, nil
+}
+
+// ownerName returns the module name of the tab which the node is placed on (or is)
+func ownerName(nodes map[string]Node, node Node) string {
+	if node.IsTab() {
+		return node.GetModuleName()
+	}
+	return nodes[node.Parent()].GetModuleName()
+}
+
+// RemoveNodes removes the given nodes along with any config nodes and subflows which
+// were used by the removed nodes and are no longer used by any of the remaining nodes
+func RemoveNodes(current []Node, ids map[string]struct{}) []Node {
+	removed := make(map[string]struct{})
+	candidates := make(map[string]struct{})
+	remove := func(id string) {
+		removed[id] = struct{}{}
+		for _, node := range current {
+			if node.ID() == id || node.Parent() == id {
+				removed[node.ID()] = struct{}{}
+				for ref := range node.References() {
+					candidates[ref] = struct{}{}
+				}
+			}
+		}
+	}
+	for id := range ids {
+		remove(id)
+	}
+
+	for {
+		used := make(map[string]struct{})
+		for _, node := range current {
+			if _, ok := removed[node.ID()]; ok {
+				continue
+			}
+			for ref := range node.References() {
+				used[ref] = struct{}{}
+			}
+		}
+
+		unused := make([]string, 0)
+		for _, node := range current {
+			id := node.ID()
+			if _, ok := removed[id]; ok || !node.IsShared() {
+				continue
+			}
+			if _, ok := candidates[id]; !ok {
+				continue
+			}
+			if _, ok := used[id]; !ok {
+				unused = append(unused, id)
+			}
+		}
+		if len(unused) == 0 {
+			break
+		}
+		for _, id := range unused {
+			remove(id)
+		}
+	}
+
+	out := make([]Node, 0, len(current))
+	for _, node := range current {
+		if _, ok := removed[node.ID()]; !ok {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+// NodeIndex returns the nodes indexed by their id
+func NodeIndex(nodes []Node) map[string]Node {
+	index := make(map[string]Node, len(nodes))
+	for _, node := range nodes {
+		index[node.ID()] = node
+	}
+	return index
+}
+
+// ChangedNodes returns the ids of the nodes which were added, removed or modified between two versions of the flows
+func ChangedNodes(before []Node, after []Node) map[string]struct{} {
+	changed := make(map[string]struct{})
+	beforeIndex := NodeIndex(before)
+	afterIndex := NodeIndex(after)
+	for id, node := range afterIndex {
+		if prev, ok := beforeIndex[id]; !ok || !reflect.DeepEqual(prev, node) {
+			changed[id] = struct{}{}
+		}
+	}
+	for id := range beforeIndex {
+		if _, ok := afterIndex[id]; !ok {
+			changed[id] = struct{}{}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,332 @@
+package nodered
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func tab(id string, module string, version string) Node {
+	return Node{
+		"id":    id,
+		"type":  "tab",
+		"label": id,
+		"env": []any{
+			map[string]any{"name": "MODULE_NAME", "value": module, "type": "str"},
+			map[string]any{"name": "MODULE_VERSION", "value": version, "type": "str"},
+		},
+	}
+}
+
+func node(id string, parent string, props ...string) Node {
+	n := Node{"id": id, "type": "inject", "z": parent}
+	for i := 0; i+1 < len(props); i += 2 {
+		n[props[i]] = props[i+1]
+	}
+	return n
+}
+
+func config(id string, props ...string) Node {
+	n := Node{"id": id, "type": "mqtt-broker"}
+	for i := 0; i+1 < len(props); i += 2 {
+		n[props[i]] = props[i+1]
+	}
+	return n
+}
+
+func ids(nodes []Node) []string {
+	out := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, n.ID())
+	}
+	return out
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		node Node
+		want []string
+	}{
+		{
+			name: "config node property",
+			node: node("n1", "t1", "broker", "b1"),
+			want: []string{"b1", "inject"},
+		},
+		{
+			name: "subflow instance",
+			node: Node{"id": "n1", "type": "subflow:s1", "z": "t1"},
+			want: []string{"s1", "subflow:s1"},
+		},
+		{
+			name: "wires and nested properties",
+			node: Node{
+				"id":    "n1",
+				"type":  "switch",
+				"z":     "t1",
+				"wires": []any{[]any{"n2", "n3"}, []any{}},
+				"rules": []any{map[string]any{"t": "eq", "v": "c1"}},
+			},
+			want: []string{"c1", "eq", "n2", "n3", "switch"},
+		},
+		{
+			name: "id and parent are ignored",
+			node: Node{"id": "n1", "type": "inject", "z": "t1"},
+			want: []string{"inject"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortedKeys(tt.node.References())
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("References() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModuleVersionNodes(t *testing.T) {
+	flows := []Node{
+		tab("t1", "a", "1.0.0"),
+		node("n1", "t1"),
+		tab("t2", "a", "2.0.0"),
+		node("n2", "t2"),
+		tab("t3", "b", "1.0.0"),
+		node("n3", "t3"),
+		config("c1"),
+	}
+	tests := []struct {
+		name    string
+		module  string
+		version string
+		want    []string
+	}{
+		{name: "any version", module: "a", want: []string{"n1", "n2", "t1", "t2"}},
+		{name: "specific version", module: "a", version: "2.0.0", want: []string{"n2", "t2"}},
+		{name: "unknown version", module: "a", version: "3.0.0", want: []string{}},
+		{name: "other module", module: "b", want: []string{"n3", "t3"}},
+		{name: "unknown module", module: "c", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortedKeys(ModuleVersionNodes(flows, tt.module, tt.version))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ModuleVersionNodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  []Node
+		module   string
+		incoming []Node
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "install into empty flows",
+			module:   "a",
+			incoming: []Node{tab("t1", "a", "1.0.0"), node("n1", "t1")},
+			want:     []string{"t1", "n1"},
+		},
+		{
+			name:     "keep other modules and editor flows",
+			current:  []Node{tab("t1", "b", "1.0.0"), node("n1", "t1"), {"id": "t2", "type": "tab", "label": "editor"}, node("n2", "t2")},
+			module:   "a",
+			incoming: []Node{tab("t3", "a", "1.0.0"), node("n3", "t3")},
+			want:     []string{"t1", "n1", "t2", "n2", "t3", "n3"},
+		},
+		{
+			name:     "replace the nodes of the previous version",
+			current:  []Node{tab("t1", "a", "1.0.0"), node("n1", "t1"), node("n2", "t1"), tab("t2", "b", "1.0.0"), node("n3", "t2")},
+			module:   "a",
+			incoming: []Node{tab("t1", "a", "2.0.0"), node("n1", "t1")},
+			want:     []string{"t2", "n3", "t1", "n1"},
+		},
+		{
+			name:     "update shared config nodes in place",
+			current:  []Node{config("c1", "broker", "old"), tab("t1", "b", "1.0.0"), node("n1", "t1", "broker", "c1")},
+			module:   "a",
+			incoming: []Node{tab("t2", "a", "1.0.0"), node("n2", "t2", "broker", "c1"), config("c1", "broker", "new")},
+			want:     []string{"c1", "t1", "n1", "t2", "n2"},
+		},
+		{
+			name: "update shared subflow in place",
+			current: []Node{
+				{"id": "s1", "type": "subflow", "name": "old"},
+				node("s1n1", "s1"),
+				tab("t1", "b", "1.0.0"),
+				{"id": "n1", "type": "subflow:s1", "z": "t1"},
+			},
+			module: "a",
+			incoming: []Node{
+				{"id": "s1", "type": "subflow", "name": "new"},
+				node("s1n1", "s1"),
+				tab("t2", "a", "1.0.0"),
+				{"id": "n2", "type": "subflow:s1", "z": "t2"},
+			},
+			want: []string{"s1", "s1n1", "t1", "n1", "t2", "n2"},
+		},
+		{
+			name:     "tab of another module",
+			current:  []Node{tab("t1", "b", "1.0.0"), node("n1", "t1")},
+			module:   "a",
+			incoming: []Node{tab("t1", "a", "1.0.0")},
+			wantErr:  true,
+		},
+		{
+			name:     "node of another module",
+			current:  []Node{tab("t1", "b", "1.0.0"), node("n1", "t1")},
+			module:   "a",
+			incoming: []Node{tab("t2", "a", "1.0.0"), node("n1", "t2")},
+			wantErr:  true,
+		},
+		{
+			name:     "node of an editor flow",
+			current:  []Node{{"id": "t1", "type": "tab", "label": "editor"}, node("n1", "t1")},
+			module:   "a",
+			incoming: []Node{tab("t2", "a", "1.0.0"), node("n1", "t2")},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeModule(tt.current, tt.module, tt.incoming)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNodeIDConflict) {
+					t.Errorf("MergeModule() error = %v, want %v", err, ErrNodeIDConflict)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeModule() unexpected error. %v", err)
+			}
+			if !slices.Equal(ids(got), tt.want) {
+				t.Errorf("MergeModule() = %v, want %v", ids(got), tt.want)
+			}
+		})
+	}
+
+	t.Run("shared config node uses the incoming value", func(t *testing.T) {
+		current := []Node{config("c1", "broker", "old")}
+		got, err := MergeModule(current, "a", []Node{config("c1", "broker", "new")})
+		if err != nil || len(got) != 1 || got[0].GetString("broker") != "new" {
+			t.Errorf("MergeModule() = %v, want the incoming config node", got)
+		}
+	})
+}
+
+func TestRemoveNodes(t *testing.T) {
+	subflow := Node{"id": "s1", "type": "subflow", "name": "shared subflow"}
+	tests := []struct {
+		name    string
+		current []Node
+		remove  []string
+		want    []string
+	}{
+		{
+			name:    "remove tab and its nodes",
+			current: []Node{tab("t1", "a", "1.0.0"), node("n1", "t1"), tab("t2", "b", "1.0.0"), node("n2", "t2")},
+			remove:  []string{"t1"},
+			want:    []string{"t2", "n2"},
+		},
+		{
+			name:    "remove unused config node",
+			current: []Node{config("c1"), tab("t1", "a", "1.0.0"), node("n1", "t1", "broker", "c1"), tab("t2", "b", "1.0.0"), node("n2", "t2")},
+			remove:  []string{"t1", "n1"},
+			want:    []string{"t2", "n2"},
+		},
+		{
+			name:    "keep config node used by another tab",
+			current: []Node{config("c1"), tab("t1", "a", "1.0.0"), node("n1", "t1", "broker", "c1"), tab("t2", "b", "1.0.0"), node("n2", "t2", "broker", "c1")},
+			remove:  []string{"t1", "n1"},
+			want:    []string{"c1", "t2", "n2"},
+		},
+		{
+			name:    "keep config node which was not used by the removed nodes",
+			current: []Node{config("c1"), tab("t1", "a", "1.0.0"), node("n1", "t1")},
+			remove:  []string{"t1", "n1"},
+			want:    []string{"c1"},
+		},
+		{
+			name: "remove unused subflow along with its nodes and config nodes",
+			current: []Node{
+				config("c1"),
+				subflow,
+				node("s1n1", "s1", "broker", "c1"),
+				tab("t1", "a", "1.0.0"),
+				{"id": "n1", "type": "subflow:s1", "z": "t1"},
+				tab("t2", "b", "1.0.0"),
+				node("n2", "t2"),
+			},
+			remove: []string{"t1", "n1"},
+			want:   []string{"t2", "n2"},
+		},
+		{
+			name: "keep subflow used by another tab",
+			current: []Node{
+				subflow,
+				node("s1n1", "s1"),
+				tab("t1", "a", "1.0.0"),
+				{"id": "n1", "type": "subflow:s1", "z": "t1"},
+				tab("t2", "b", "1.0.0"),
+				{"id": "n2", "type": "subflow:s1", "z": "t2"},
+			},
+			remove: []string{"t1", "n1"},
+			want:   []string{"s1", "s1n1", "t2", "n2"},
+		},
+		{
+			name: "keep config node used by a remaining config node",
+			current: []Node{
+				config("tls1"),
+				config("c1", "tls", "tls1"),
+				tab("t1", "a", "1.0.0"),
+				node("n1", "t1", "tls", "tls1"),
+				tab("t2", "b", "1.0.0"),
+				node("n2", "t2", "broker", "c1"),
+			},
+			remove: []string{"t1", "n1"},
+			want:   []string{"tls1", "c1", "t2", "n2"},
+		},
+		{
+			name:    "nothing to remove",
+			current: []Node{tab("t1", "a", "1.0.0"), node("n1", "t1")},
+			remove:  []string{},
+			want:    []string{"t1", "n1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remove := make(map[string]struct{})
+			for _, id := range tt.remove {
+				remove[id] = struct{}{}
+			}
+			got := RemoveNodes(tt.current, remove)
+			if !slices.Equal(ids(got), tt.want) {
+				t.Errorf("RemoveNodes() = %v, want %v", ids(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestChangedNodes(t *testing.T) {
+	before := []Node{tab("t1", "a", "1.0.0"), node("n1", "t1", "name", "old"), node("n2", "t1")}
+	after := []Node{tab("t1", "a", "1.0.0"), node("n1", "t1", "name", "new"), node("n3", "t1")}
+
+	got := sortedKeys(ChangedNodes(before, after))
+	want := []string{"n1", "n2", "n3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ChangedNodes() = %v, want %v", got, want)
+	}
+	if got := ChangedNodes(before, before); len(got) != 0 {
+		t.Errorf("ChangedNodes() = %v, want none", sortedKeys(got))
+	}
+}
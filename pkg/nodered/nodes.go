@@ -0,0 +1,152 @@
+package nodered
+
+import (
+	"context"
+	"sort"
+)
+
+// CoreModule is the name of the module which provides the core nodes
+const CoreModule = "node-red"
+
+// NodeSet is a set of node types provided by a module
+type NodeSet struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Types   []string `json:"types"`
+	Enabled bool     `json:"enabled"`
+	Local   bool     `json:"local"`
+	User    bool     `json:"user"`
+	Module  string   `json:"module"`
+	Version string   `json:"version"`
+}
+
+// NodeModule is a module which provides one or more node sets
+type NodeModule struct {
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+	Nodes   []NodeSet `json:"nodes,omitempty"`
+}
+
+// Get a list of the installed node sets
+// Docs: https://nodered.org/docs/api/admin/methods/get/nodes/
+func (c *Client) GetNodes() ([]NodeSet, error) {
+	return c.GetNodesContext(c.context())
+}
+
+// GetNodesContext is the same as GetNodes, but uses the given context
+func (c *Client) GetNodesContext(ctx context.Context) ([]NodeSet, error) {
+	data := make([]NodeSet, 0)
+	_, err := c.api.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetResult(&data).
+		Get("nodes")
+	return data, err
+}
+
+// GetNodeModules returns the installed modules, excluding the core module
+func (c *Client) GetNodeModules() ([]NodeModule, error) {
+	return c.GetNodeModulesContext(c.context())
+}
+
+// GetNodeModulesContext is the same as GetNodeModules, but uses the given context
+func (c *Client) GetNodeModulesContext(ctx context.Context) ([]NodeModule, error) {
+	nodes, err := c.GetNodesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	modules := make([]NodeModule, 0)
+	for _, node := range nodes {
+		if node.Module == CoreModule {
+			continue
+		}
+		i, ok := index[node.Module]
+		if !ok {
+			i = len(modules)
+			index[node.Module] = i
+			modules = append(modules, NodeModule{
+				Name:    node.Module,
+				Version: node.Version,
+			})
+		}
+		modules[i].Nodes = append(modules[i].Nodes, node)
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+	return modules, nil
+}
+
+// GetNodeTypes returns all of the registered node types
+func (c *Client) GetNodeTypes() (map[string]struct{}, error) {
+	return c.GetNodeTypesContext(c.context())
+}
+
+// GetNodeTypesContext is the same as GetNodeTypes, but uses the given context
+func (c *Client) GetNodeTypesContext(ctx context.Context) (map[string]struct{}, error) {
+	nodes, err := c.GetNodesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]struct{})
+	for _, node := range nodes {
+		for _, t := range node.Types {
+			types[t] = struct{}{}
+		}
+	}
+	return types, nil
+}
+
+// Install a module from the npm registry. If the module is already installed
+// with a different version, then node-red will update it
+// Docs: https://nodered.org/docs/api/admin/methods/post/nodes/
+func (c *Client) InstallNodeModule(module string, version string) (*NodeModule, error) {
+	return c.InstallNodeModuleContext(c.context(), module, version)
+}
+
+// InstallNodeModuleContext is the same as InstallNodeModule, but uses the given context
+func (c *Client) InstallNodeModuleContext(ctx context.Context, module string, version string) (*NodeModule, error) {
+	body := map[string]string{
+		"module": module,
+	}
+	if version != "" {
+		body["version"] = version
+	}
+	data := &NodeModule{}
+	_, err := c.api.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetBody(body).
+		SetResult(data).
+		Post("nodes")
+	return data, err
+}
+
+// Install a module from a tarball (e.g. created by npm pack)
+// Docs: https://nodered.org/docs/api/admin/methods/post/nodes/
+func (c *Client) InstallNodeModuleFromFile(path string) (*NodeModule, error) {
+	return c.InstallNodeModuleFromFileContext(c.context(), path)
+}
+
+// InstallNodeModuleFromFileContext is the same as InstallNodeModuleFromFile, but uses the given context
+func (c *Client) InstallNodeModuleFromFileContext(ctx context.Context, path string) (*NodeModule, error) {
+	data := &NodeModule{}
+	_, err := c.api.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetFile("tarball", path).
+		SetResult(data).
+		Post("nodes")
+	return data, err
+}
+
+// Remove a module
+// Docs: https://nodered.org/docs/api/admin/methods/delete/nodes/module/
+func (c *Client) RemoveNodeModule(module string) error {
+	return c.RemoveNodeModuleContext(c.context(), module)
+}
+
+// RemoveNodeModuleContext is the same as RemoveNodeModule, but uses the given context
+func (c *Client) RemoveNodeModuleContext(ctx context.Context, module string) error {
+	_, err := c.api.R().SetContext(ctx).Delete("nodes/" + module)
+	return err
+}
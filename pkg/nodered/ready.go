@@ -0,0 +1,111 @@
+package nodered
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrNotReady = errors.New("node-red is not ready")
+
+const (
+	FlowsStateStart = "start"
+	FlowsStateStop  = "stop"
+)
+
+// Maximum duration of a single readiness probe
+const probeTimeout = 5 * time.Second
+
+type FlowsState struct {
+	State string `json:"state"`
+}
+
+// probe sends a single request without any retries, as the caller is responsible for polling
+func (c *Client) probe(ctx context.Context, path string, out any) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.api.BaseURL, "/")+"/"+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Node-RED-API-Version", "v2")
+
+	resp, err := c.api.GetClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, fmt.Errorf("unexpected status. path=%s, status=%s", path, resp.Status)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// checkReady checks if the admin api is available and the flows have been started.
+// The flows state is only checked if it is supported, as it requires node-red >= 3.1
+// with the runtimeState setting enabled
+func (c *Client) checkReady(ctx context.Context) error {
+	if _, err := c.probe(ctx, "settings", nil); err != nil {
+		return err
+	}
+
+	state := &FlowsState{}
+	status, err := c.probe(ctx, "flows/state", state)
+	if err != nil {
+		switch status {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusMethodNotAllowed:
+			// Not supported
+			return nil
+		}
+		return err
+	}
+	if state.State != "" && state.State != FlowsStateStart {
+		return fmt.Errorf("flows are not started. state=%s", state.State)
+	}
+	return nil
+}
+
+// WaitForReady polls node-red until its runtime has started, or the timeout is reached
+func (c *Client) WaitForReady(timeout time.Duration, interval time.Duration) error {
+	return c.WaitForReadyContext(c.context(), timeout, interval)
+}
+
+// WaitForReadyContext is the same as WaitForReady, but uses the given context
+func (c *Client) WaitForReadyContext(ctx context.Context, timeout time.Duration, interval time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := c.checkReady(ctx)
+		if err == nil {
+			slog.Info("node-red is ready.", "attempts", attempt, "duration", time.Since(start).Round(time.Millisecond))
+			return nil
+		}
+		slog.Info("Waiting for node-red to be ready.", "attempt", attempt, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w. timeout=%s. %w", ErrNotReady, timeout, err)
+		case <-time.After(interval):
+		}
+	}
+}
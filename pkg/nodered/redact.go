@@ -0,0 +1,97 @@
+package nodered
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Redacted is the value used in place of sensitive values in logs
+const Redacted = "<redacted>"
+
+// Keys whose values must never be logged
+var sensitiveKeys = map[string]struct{}{
+	"credentials":      {},
+	"credentialsecret": {},
+	"password":         {},
+	"passphrase":       {},
+	"token":            {},
+	"access_token":     {},
+	"refresh_token":    {},
+	"client_secret":    {},
+	"privatekey":       {},
+}
+
+func isSensitiveKey(k string) bool {
+	_, ok := sensitiveKeys[strings.ToLower(k)]
+	return ok
+}
+
+func redactValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, item := range value {
+			if isSensitiveKey(k) {
+				value[k] = Redacted
+			} else {
+				value[k] = redactValue(item)
+			}
+		}
+		return value
+	case []any:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+		return value
+	}
+	return v
+}
+
+// RedactBody replaces the values of sensitive keys in a json or form encoded body
+func RedactBody(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" {
+		return body
+	}
+
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var data any
+		if err := json.Unmarshal([]byte(trimmed), &data); err != nil {
+			// Don't risk logging a partially parsed body
+			return Redacted
+		}
+		out := &strings.Builder{}
+		encoder := json.NewEncoder(out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "   ")
+		if err := encoder.Encode(redactValue(data)); err != nil {
+			return Redacted
+		}
+		return strings.TrimSuffix(out.String(), "\n")
+	}
+
+	if form, err := url.ParseQuery(trimmed); err == nil && len(form) > 0 && !strings.ContainsAny(trimmed, " \n") {
+		for k := range form {
+			if isSensitiveKey(k) {
+				form.Set(k, Redacted)
+			}
+		}
+		return form.Encode()
+	}
+	return body
+}
+
+// redactRequestLog prevents sensitive values being included in the debug logs
+func redactRequestLog(l *resty.RequestLog) error {
+	l.Header.Del("Authorization")
+	l.Body = RedactBody(l.Body)
+	return nil
+}
+
+func redactResponseLog(l *resty.ResponseLog) error {
+	l.Header.Del("Set-Cookie")
+	l.Body = RedactBody(l.Body)
+	return nil
+}
@@ -0,0 +1,147 @@
+package nodered
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// DefaultRetryStatusCodes are the status codes which are returned while node-red
+// is starting or restarting (e.g. behind a reverse proxy)
+var DefaultRetryStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy controls which failed requests are retried, and how long to wait between the attempts
+type RetryPolicy struct {
+	// Maximum number of retries after the first attempt
+	Count int
+
+	// Wait before the first retry, which is doubled for each following retry
+	WaitTime    time.Duration
+	MaxWaitTime time.Duration
+
+	// Maximum random duration which is added to each wait
+	Jitter time.Duration
+
+	// Response status codes which are retried
+	StatusCodes []int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Count:       3,
+		WaitTime:    10 * time.Second,
+		MaxWaitTime: 60 * time.Second,
+		Jitter:      time.Second,
+		StatusCodes: slices.Clone(DefaultRetryStatusCodes),
+	}
+}
+
+type idempotentKey struct{}
+
+// idempotent marks a request as safe to retry, even if it uses a non-idempotent method (e.g. POST)
+func idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(r *resty.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	v, _ := r.Context().Value(idempotentKey{}).(bool)
+	return v
+}
+
+// isNotSent checks if the request failed before it was sent to node-red, e.g. node-red is not listening yet
+func isNotSent(err error) bool {
+	opErr := &net.OpError{}
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// shouldRetry checks if a failed request can be retried. Requests which are not idempotent are
+// only retried if node-red did not process them, e.g. the connection was refused, or node-red
+// responded that it is not available yet
+func (p RetryPolicy) shouldRetry(r *resty.Response, err error) bool {
+	if r == nil || r.Request == nil {
+		return false
+	}
+	if ctxErr := r.Request.Context().Err(); ctxErr != nil {
+		return false
+	}
+	req := r.Request
+	retry := false
+	status := 0
+	if r.RawResponse == nil {
+		if err == nil {
+			return false
+		}
+		retry = isIdempotent(req) || isNotSent(err)
+	} else {
+		status = r.StatusCode()
+		if slices.Contains(p.StatusCodes, status) {
+			retry = isIdempotent(req) || status == http.StatusServiceUnavailable
+		}
+	}
+	if !retry {
+		return false
+	}
+
+	attrs := []any{"method", req.Method, "url", req.URL, "attempt", req.Attempt, "retries", p.Count}
+	if status != 0 {
+		attrs = append(attrs, "status", status)
+	} else {
+		attrs = append(attrs, "err", err)
+	}
+	if req.Attempt <= p.Count {
+		slog.Warn("Request failed, retrying.", attrs...)
+	}
+	return true
+}
+
+// retryAfter returns the wait before the next attempt. The Retry-After header of the
+// response takes precedence, otherwise an exponential backoff with jitter is used
+func (p RetryPolicy) retryAfter(c *resty.Client, r *resty.Response) (time.Duration, error) {
+	if r != nil && r.RawResponse != nil {
+		if seconds, err := strconv.Atoi(r.Header().Get("Retry-After")); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second, nil
+		}
+	}
+	attempt := 1
+	if r != nil && r.Request != nil {
+		attempt = r.Request.Attempt
+	}
+	wait := p.WaitTime
+	for i := 1; i < attempt && wait < p.MaxWaitTime; i++ {
+		wait *= 2
+	}
+	if p.MaxWaitTime > 0 && wait > p.MaxWaitTime {
+		wait = p.MaxWaitTime
+	}
+	if p.Jitter > 0 {
+		wait += rand.N(p.Jitter)
+	}
+	return wait, nil
+}
+
+// SetRetryPolicy sets the policy used to retry failed requests
+func (c *Client) SetRetryPolicy(policy RetryPolicy) *Client {
+	c.api.RetryConditions = nil
+	c.api.SetRetryCount(policy.Count).
+		SetRetryWaitTime(policy.WaitTime).
+		SetRetryMaxWaitTime(policy.MaxWaitTime + policy.Jitter).
+		SetRetryAfter(policy.retryAfter).
+		AddRetryCondition(policy.shouldRetry)
+	return c
+}
@@ -0,0 +1,101 @@
+package nodered
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func TestShouldRetry(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		method string
+		ctx    context.Context
+		status int
+		err    error
+		want   bool
+	}{
+		{name: "idempotent request on 502", method: http.MethodGet, status: http.StatusBadGateway, want: true},
+		{name: "idempotent request on 503", method: http.MethodPut, status: http.StatusServiceUnavailable, want: true},
+		{name: "idempotent request on dial error", method: http.MethodGet, err: dialErr, want: true},
+		{name: "idempotent request on read error", method: http.MethodDelete, err: readErr, want: true},
+		{name: "idempotent request on unlisted status", method: http.MethodGet, status: http.StatusInternalServerError, want: false},
+		{name: "idempotent request on success", method: http.MethodGet, status: http.StatusOK, want: false},
+		{name: "post on 502", method: http.MethodPost, status: http.StatusBadGateway, want: false},
+		{name: "post on 504", method: http.MethodPost, status: http.StatusGatewayTimeout, want: false},
+		{name: "post on 503 was not processed by node-red", method: http.MethodPost, status: http.StatusServiceUnavailable, want: true},
+		{name: "post on dial error was not sent", method: http.MethodPost, err: dialErr, want: true},
+		{name: "post on read error may have been processed", method: http.MethodPost, err: readErr, want: false},
+		{name: "post marked as idempotent on 502", method: http.MethodPost, ctx: idempotent(context.Background()), status: http.StatusBadGateway, want: true},
+		{name: "post marked as idempotent on read error", method: http.MethodPost, ctx: idempotent(context.Background()), err: readErr, want: true},
+		{name: "cancelled request", method: http.MethodGet, ctx: cancelled, err: dialErr, want: false},
+	}
+	policy := DefaultRetryPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := resty.New().R()
+			req.Method = tt.method
+			if tt.ctx != nil {
+				req.SetContext(tt.ctx)
+			}
+			resp := &resty.Response{Request: req}
+			if tt.status != 0 {
+				resp.RawResponse = &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			}
+			if got := policy.shouldRetry(resp, tt.err); got != tt.want {
+				t.Errorf("shouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		status       int
+		wantAttempts int32
+	}{
+		{name: "get is retried", method: http.MethodGet, status: http.StatusBadGateway, wantAttempts: 3},
+		{name: "post is not retried", method: http.MethodPost, status: http.StatusBadGateway, wantAttempts: 1},
+		{name: "post is retried when node-red is unavailable", method: http.MethodPost, status: http.StatusServiceUnavailable, wantAttempts: 3},
+		{name: "client errors are not retried", method: http.MethodGet, status: http.StatusBadRequest, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := atomic.Int32{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := NewClientWithoutRetries(server.URL)
+			client.SetRetryPolicy(RetryPolicy{
+				Count:       2,
+				WaitTime:    time.Millisecond,
+				MaxWaitTime: time.Millisecond,
+				StatusCodes: DefaultRetryStatusCodes,
+			})
+			_, err := client.api.R().Execute(tt.method, "flows")
+			if err == nil {
+				t.Errorf("Execute() expected an error")
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
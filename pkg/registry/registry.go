@@ -0,0 +1,243 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/thin-edge/tedge-nodered-plugin/pkg/utils"
+)
+
+var ErrDrift = errors.New("installed modules do not match the registry")
+
+// Module is a module which was installed by the plugin
+type Module struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Checksum    string    `json:"checksum,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+
+	// Ids of the nodes owned by the module (only used by flows)
+	Nodes []string `json:"nodes,omitempty"`
+
+	// Git tag or commit which the version is pinned to (only used by projects)
+	Ref string `json:"ref,omitempty"`
+}
+
+// Registry records the modules which were installed by the plugin for each software type.
+// It is stored as a json file, and is reconciled against the live node-red state
+type Registry struct {
+	path    string
+	Modules map[string]map[string]*Module `json:"modules"`
+}
+
+// New returns an empty registry which is stored in the given file
+func New(path string) *Registry {
+	return &Registry{
+		path:    path,
+		Modules: make(map[string]map[string]*Module),
+	}
+}
+
+// Open reads the registry from a file. A missing file results in an empty registry
+func Open(path string) (*Registry, error) {
+	r := New(path)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return r, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("invalid registry file. path=%s. %w", path, err)
+	}
+	if r.Modules == nil {
+		r.Modules = make(map[string]map[string]*Module)
+	}
+	return r, nil
+}
+
+// Save writes the registry to its file
+func (r *Registry) Save() error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(r.path, b, 0644)
+}
+
+// Get returns a module of the given software type
+func (r *Registry) Get(softwareType string, name string) (*Module, bool) {
+	module, ok := r.Modules[softwareType][name]
+	return module, ok
+}
+
+// List returns all modules of the given software type sorted by name
+func (r *Registry) List(softwareType string) []*Module {
+	modules := make([]*Module, 0, len(r.Modules[softwareType]))
+	for _, module := range r.Modules[softwareType] {
+		modules = append(modules, module)
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Name < modules[j].Name
+	})
+	return modules
+}
+
+// Put adds or replaces a module of the given software type
+func (r *Registry) Put(softwareType string, module *Module) {
+	if _, ok := r.Modules[softwareType]; !ok {
+		r.Modules[softwareType] = make(map[string]*Module)
+	}
+	if module.InstalledAt.IsZero() {
+		module.InstalledAt = time.Now().UTC().Truncate(time.Second)
+	}
+	r.Modules[softwareType][module.Name] = module
+}
+
+// Delete removes a module of the given software type
+func (r *Registry) Delete(softwareType string, name string) {
+	delete(r.Modules[softwareType], name)
+}
+
+// Checksum returns the sha256 checksum of a file. An empty path returns an empty checksum
+func Checksum(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// LiveModule is a module as it is currently found in node-red
+type LiveModule struct {
+	Name string
+
+	// Version reported by node-red. An empty value means the version is unknown
+	Version string
+
+	// Ids of the nodes owned by the module (only used by flows)
+	Nodes []string
+}
+
+type DriftType string
+
+const (
+	// DriftMissing is a module in the registry which is no longer installed
+	DriftMissing DriftType = "missing"
+	// DriftUnmanaged is a module which is installed but was not installed by the plugin
+	DriftUnmanaged DriftType = "unmanaged"
+	// DriftVersion is a module whose version differs from the installed version
+	DriftVersion DriftType = "version"
+	// DriftNodes is a module whose nodes were added or removed since it was installed
+	DriftNodes DriftType = "nodes"
+	// DriftRenamed is a module whose nodes are now found under a different name
+	DriftRenamed DriftType = "renamed"
+)
+
+// Drift is a difference between the registry and the live node-red state
+type Drift struct {
+	Type     DriftType
+	Name     string
+	Expected string
+	Actual   string
+}
+
+// Reconcile compares the registry with the modules which are currently installed in node-red.
+// Modules which are no longer installed are removed from the registry, all other differences
+// (including modules which were renamed in the editor) are only reported, as the registry
+// records what the plugin installed
+func (r *Registry) Reconcile(softwareType string, live []LiveModule) []Drift {
+	drift := make([]Drift, 0)
+	found := make(map[string]struct{}, len(live))
+	owners := make(map[string]string)
+	for _, item := range live {
+		found[item.Name] = struct{}{}
+		for _, id := range item.Nodes {
+			owners[id] = item.Name
+		}
+	}
+
+	// Modules which were renamed are matched by the nodes they own
+	renamed := make(map[string]string)
+	kept := make(map[string]struct{})
+	for _, module := range r.List(softwareType) {
+		if _, ok := found[module.Name]; ok {
+			continue
+		}
+		for _, id := range module.Nodes {
+			if owner, ok := owners[id]; ok {
+				renamed[owner] = module.Name
+				kept[module.Name] = struct{}{}
+				break
+			}
+		}
+	}
+
+	for _, item := range live {
+		if name, ok := renamed[item.Name]; ok {
+			drift = append(drift, Drift{Type: DriftRenamed, Name: name, Expected: name, Actual: item.Name})
+			continue
+		}
+		module, ok := r.Get(softwareType, item.Name)
+		if !ok {
+			drift = append(drift, Drift{Type: DriftUnmanaged, Name: item.Name, Actual: item.Version})
+			continue
+		}
+		if item.Version != "" && item.Version != module.Version {
+			drift = append(drift, Drift{Type: DriftVersion, Name: item.Name, Expected: module.Version, Actual: item.Version})
+		}
+		if len(module.Nodes) > 0 && !sameNodes(module.Nodes, item.Nodes) {
+			drift = append(drift, Drift{
+				Type:     DriftNodes,
+				Name:     item.Name,
+				Expected: fmt.Sprintf("%d", len(module.Nodes)),
+				Actual:   fmt.Sprintf("%d", len(item.Nodes)),
+			})
+		}
+	}
+
+	for _, module := range r.List(softwareType) {
+		if _, ok := found[module.Name]; ok {
+			continue
+		}
+		if _, ok := kept[module.Name]; ok {
+			continue
+		}
+		drift = append(drift, Drift{Type: DriftMissing, Name: module.Name, Expected: module.Version})
+		r.Delete(softwareType, module.Name)
+	}
+	return drift
+}
+
+// Changed checks if reconciling changed the registry, which is only the case if missing modules were removed
+func Changed(drift []Drift) bool {
+	return slices.ContainsFunc(drift, func(item Drift) bool {
+		return item.Type == DriftMissing
+	})
+}
+
+func sameNodes(a []string, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,192 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRegistry(t *testing.T, modules ...*Module) *Registry {
+	t.Helper()
+	r := New(filepath.Join(t.TempDir(), "registry.json"))
+	for _, module := range modules {
+		r.Put("nodered-flows", module)
+	}
+	return r
+}
+
+func names(r *Registry, softwareType string) []string {
+	out := make([]string, 0)
+	for _, module := range r.List(softwareType) {
+		out = append(out, module.Name)
+	}
+	return out
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name      string
+		modules   []*Module
+		live      []LiveModule
+		wantDrift []Drift
+		wantNames []string
+		changed   bool
+	}{
+		{
+			name:      "in sync",
+			modules:   []*Module{{Name: "a", Version: "1.0.0", Nodes: []string{"t1", "n1"}}},
+			live:      []LiveModule{{Name: "a", Version: "1.0.0", Nodes: []string{"n1", "t1"}}},
+			wantDrift: []Drift{},
+			wantNames: []string{"a"},
+		},
+		{
+			name:      "missing module is removed",
+			modules:   []*Module{{Name: "a", Version: "1.0.0", Nodes: []string{"t1"}}, {Name: "b", Version: "2.0.0", Nodes: []string{"t2"}}},
+			live:      []LiveModule{{Name: "a", Version: "1.0.0", Nodes: []string{"t1"}}},
+			wantDrift: []Drift{{Type: DriftMissing, Name: "b", Expected: "2.0.0"}},
+			wantNames: []string{"a"},
+			changed:   true,
+		},
+		{
+			name:      "unmanaged module",
+			live:      []LiveModule{{Name: "a", Version: "1.0.0"}},
+			wantDrift: []Drift{{Type: DriftUnmanaged, Name: "a", Actual: "1.0.0"}},
+			wantNames: []string{},
+		},
+		{
+			name:      "version changed",
+			modules:   []*Module{{Name: "a", Version: "1.0.0"}},
+			live:      []LiveModule{{Name: "a", Version: "1.1.0"}},
+			wantDrift: []Drift{{Type: DriftVersion, Name: "a", Expected: "1.0.0", Actual: "1.1.0"}},
+			wantNames: []string{"a"},
+		},
+		{
+			name:      "unknown live version is ignored",
+			modules:   []*Module{{Name: "a", Version: "1.0.0"}},
+			live:      []LiveModule{{Name: "a"}},
+			wantDrift: []Drift{},
+			wantNames: []string{"a"},
+		},
+		{
+			name:      "nodes added in the editor",
+			modules:   []*Module{{Name: "a", Version: "1.0.0", Nodes: []string{"t1", "n1"}}},
+			live:      []LiveModule{{Name: "a", Version: "1.0.0", Nodes: []string{"t1", "n1", "n2"}}},
+			wantDrift: []Drift{{Type: DriftNodes, Name: "a", Expected: "2", Actual: "3"}},
+			wantNames: []string{"a"},
+		},
+		{
+			name:      "modules without recorded nodes are not compared",
+			modules:   []*Module{{Name: "a", Version: "1.0.0"}},
+			live:      []LiveModule{{Name: "a", Version: "1.0.0", Nodes: []string{"t1"}}},
+			wantDrift: []Drift{},
+			wantNames: []string{"a"},
+		},
+		{
+			name:      "renamed module is kept",
+			modules:   []*Module{{Name: "a", Version: "1.0.0", Nodes: []string{"t1", "n1"}}},
+			live:      []LiveModule{{Name: "renamed", Version: "1.0.0", Nodes: []string{"t1", "n1"}}},
+			wantDrift: []Drift{{Type: DriftRenamed, Name: "a", Expected: "a", Actual: "renamed"}},
+			wantNames: []string{"a"},
+		},
+		{
+			name:    "renamed and missing modules",
+			modules: []*Module{{Name: "a", Version: "1.0.0", Nodes: []string{"t1"}}, {Name: "b", Version: "1.0.0", Nodes: []string{"t2"}}},
+			live:    []LiveModule{{Name: "renamed", Nodes: []string{"t1"}}},
+			wantDrift: []Drift{
+				{Type: DriftRenamed, Name: "a", Expected: "a", Actual: "renamed"},
+				{Type: DriftMissing, Name: "b", Expected: "1.0.0"},
+			},
+			wantNames: []string{"a"},
+			changed:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRegistry(t, tt.modules...)
+			drift := r.Reconcile("nodered-flows", tt.live)
+			if !slices.Equal(drift, tt.wantDrift) {
+				t.Errorf("Reconcile() = %v, want %v", drift, tt.wantDrift)
+			}
+			if got := names(r, "nodered-flows"); !slices.Equal(got, tt.wantNames) {
+				t.Errorf("Reconcile() registry = %v, want %v", got, tt.wantNames)
+			}
+			if got := Changed(drift); got != tt.changed {
+				t.Errorf("Changed() = %v, want %v", got, tt.changed)
+			}
+		})
+	}
+}
+
+func TestReconcileOnlyChangesTheGivenSoftwareType(t *testing.T) {
+	r := newRegistry(t, &Module{Name: "a", Version: "1.0.0"})
+	r.Put("nodered-nodes", &Module{Name: "node-red-contrib-x", Version: "1.0.0"})
+
+	r.Reconcile("nodered-flows", nil)
+	if got := names(r, "nodered-flows"); len(got) != 0 {
+		t.Errorf("Reconcile() flows = %v, want none", got)
+	}
+	if got := names(r, "nodered-nodes"); !slices.Equal(got, []string{"node-red-contrib-x"}) {
+		t.Errorf("Reconcile() nodes = %v, want [node-red-contrib-x]", got)
+	}
+}
+
+func TestOpenAndSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "registry.json")
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() missing file. %v", err)
+	}
+	installedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.Put("nodered-flows", &Module{Name: "a", Version: "1.0.0", Checksum: "sha256:abc", InstalledAt: installedAt, Nodes: []string{"t1"}})
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save() %v", err)
+	}
+
+	r, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() %v", err)
+	}
+	module, ok := r.Get("nodered-flows", "a")
+	if !ok {
+		t.Fatalf("Get() module not found")
+	}
+	if module.Version != "1.0.0" || module.Checksum != "sha256:abc" || !module.InstalledAt.Equal(installedAt) || !slices.Equal(module.Nodes, []string{"t1"}) {
+		t.Errorf("Get() = %+v", module)
+	}
+}
+
+func TestOpenInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Errorf("Open() expected an error")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flows.json")
+	if err := os.WriteFile(path, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Checksum(path)
+	if err != nil {
+		t.Fatalf("Checksum() %v", err)
+	}
+	want := "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+	if got != want {
+		t.Errorf("Checksum() = %s, want %s", got, want)
+	}
+
+	if got, err := Checksum(""); err != nil || got != "" {
+		t.Errorf("Checksum() empty path = %q, %v", got, err)
+	}
+	if _, err := Checksum(filepath.Join(t.TempDir(), "missing")); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("Checksum() missing file error = %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package tedge
+
+import (
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var DefaultConfigPath = "/etc/tedge/tedge.toml"
+
+// Settings contains the thin-edge.io settings which are useful for flows
+type Settings struct {
+	MQTTHost      string
+	MQTTPort      int
+	TopicRoot     string
+	DeviceTopicID string
+
+	// MQTT client TLS settings
+	CAFile   string
+	CADir    string
+	CertFile string
+	KeyFile  string
+}
+
+// ReadSettings reads the thin-edge.io settings from the tedge.toml file.
+// The thin-edge.io defaults are used for any settings which are not set (or if the file does not exist)
+func ReadSettings(path string) (*Settings, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	v.SetDefault("mqtt.client.host", "localhost")
+	v.SetDefault("mqtt.client.port", 1883)
+	v.SetDefault("mqtt.topic_root", "te")
+	v.SetDefault("mqtt.device_topic_id", "device/main//")
+
+	if err := v.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return &Settings{
+		MQTTHost:      v.GetString("mqtt.client.host"),
+		MQTTPort:      v.GetInt("mqtt.client.port"),
+		TopicRoot:     v.GetString("mqtt.topic_root"),
+		DeviceTopicID: v.GetString("mqtt.device_topic_id"),
+		CAFile:        v.GetString("mqtt.client.auth.ca_file"),
+		CADir:         v.GetString("mqtt.client.auth.ca_dir"),
+		CertFile:      v.GetString("mqtt.client.auth.cert_file"),
+		KeyFile:       v.GetString("mqtt.client.auth.key_file"),
+	}, nil
+}
+
+// DeviceTopic returns the topic prefix of the device, e.g. te/device/main//
+func (s *Settings) DeviceTopic() string {
+	return strings.TrimSuffix(s.TopicRoot, "/") + "/" + s.DeviceTopicID
+}
+
+// Variables returns the settings as environment variables. Settings which are not set are not included
+func (s *Settings) Variables() map[string]string {
+	values := map[string]string{
+		"TEDGE_MQTT_HOST":             s.MQTTHost,
+		"TEDGE_MQTT_PORT":             strconv.Itoa(s.MQTTPort),
+		"TEDGE_TOPIC_ROOT":            s.TopicRoot,
+		"TEDGE_DEVICE_TOPIC_ID":       s.DeviceTopicID,
+		"TEDGE_DEVICE_TOPIC":          s.DeviceTopic(),
+		"TEDGE_MQTT_CLIENT_CA_FILE":   s.CAFile,
+		"TEDGE_MQTT_CLIENT_CA_DIR":    s.CADir,
+		"TEDGE_MQTT_CLIENT_CERT_FILE": s.CertFile,
+		"TEDGE_MQTT_CLIENT_KEY_FILE":  s.KeyFile,
+	}
+	for key, value := range values {
+		if value == "" {
+			delete(values, key)
+		}
+	}
+	return values
+}
@@ -0,0 +1,96 @@
+package tedge
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		missing  bool
+		contents string
+		want     Settings
+	}{
+		{
+			name:    "missing file uses the defaults",
+			missing: true,
+			want:    Settings{MQTTHost: "localhost", MQTTPort: 1883, TopicRoot: "te", DeviceTopicID: "device/main//"},
+		},
+		{
+			name:     "empty file uses the defaults",
+			contents: "",
+			want:     Settings{MQTTHost: "localhost", MQTTPort: 1883, TopicRoot: "te", DeviceTopicID: "device/main//"},
+		},
+		{
+			name: "custom settings",
+			contents: `
+[mqtt]
+topic_root = "custom"
+device_topic_id = "device/child01//"
+
+[mqtt.client]
+host = "mosquitto"
+port = 8883
+
+[mqtt.client.auth]
+ca_file = "/etc/ssl/ca.pem"
+cert_file = "/etc/tedge/client.pem"
+key_file = "/etc/tedge/client.key"
+`,
+			want: Settings{
+				MQTTHost:      "mosquitto",
+				MQTTPort:      8883,
+				TopicRoot:     "custom",
+				DeviceTopicID: "device/child01//",
+				CAFile:        "/etc/ssl/ca.pem",
+				CertFile:      "/etc/tedge/client.pem",
+				KeyFile:       "/etc/tedge/client.key",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tedge.toml")
+			if !tt.missing {
+				if err := os.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := ReadSettings(path)
+			if err != nil {
+				t.Fatalf("ReadSettings() unexpected error. %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("ReadSettings() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSettingsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tedge.toml")
+	if err := os.WriteFile(path, []byte("[mqtt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSettings(path); err == nil {
+		t.Errorf("ReadSettings() expected an error")
+	}
+}
+
+func TestVariables(t *testing.T) {
+	settings := &Settings{MQTTHost: "localhost", MQTTPort: 1883, TopicRoot: "te/", DeviceTopicID: "device/main//", CAFile: "/etc/ssl/ca.pem"}
+	want := map[string]string{
+		"TEDGE_MQTT_HOST":           "localhost",
+		"TEDGE_MQTT_PORT":           "1883",
+		"TEDGE_TOPIC_ROOT":          "te/",
+		"TEDGE_DEVICE_TOPIC_ID":     "device/main//",
+		"TEDGE_DEVICE_TOPIC":        "te/device/main//",
+		"TEDGE_MQTT_CLIENT_CA_FILE": "/etc/ssl/ca.pem",
+	}
+	if got := settings.Variables(); !maps.Equal(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+type ArchiveFormat string
+
+const (
+	FormatUnknown ArchiveFormat = ""
+	FormatTarGz   ArchiveFormat = "tar.gz"
+	FormatZip     ArchiveFormat = "zip"
+)
+
+// MaxArchiveEntrySize is the maximum size of a single file which is read from an archive
+const MaxArchiveEntrySize = 64 * 1024 * 1024
+
+var ErrArchiveEntryTooLarge = errors.New("archive entry is too large")
+
+// DetectArchiveFormat detects the archive format of a file from its magic bytes
+func DetectArchiveFormat(p string) (ArchiveFormat, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 4)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return FormatUnknown, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return FormatZip, nil
+	}
+	return FormatUnknown, nil
+}
+
+// archiveEntryName normalizes the name of an archive entry, and splits it into its top level
+// directory and the name within that directory
+func archiveEntryName(name string) (string, string) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	root, rest, found := strings.Cut(name, "/")
+	if !found {
+		return "", name
+	}
+	return root, rest
+}
+
+// ReadArchiveFiles reads the given files from a .tar.gz or .zip archive.
+// If all of the entries are inside a single top level directory (e.g. the archive was created
+// from a directory), then the files are read from that directory, otherwise from the root.
+// Files which do not exist in the archive are not included in the result
+func ReadArchiveFiles(p string, names ...string) (map[string][]byte, error) {
+	format, err := DetectArchiveFormat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		wanted[name] = struct{}{}
+	}
+
+	// The files can only be selected once the layout of the whole archive is known
+	candidates := make(map[string]map[string][]byte)
+	roots := make(map[string]struct{})
+
+	readEntry := func(name string, r io.Reader) error {
+		root, name := archiveEntryName(name)
+		if root == "__MACOSX" {
+			// Metadata added by the macOS archive utility
+			return nil
+		}
+		roots[root] = struct{}{}
+		if _, ok := wanted[name]; !ok {
+			return nil
+		}
+		if _, exists := candidates[root][name]; exists {
+			return fmt.Errorf("duplicate archive entry. name=%s", path.Join(root, name))
+		}
+		b, err := io.ReadAll(io.LimitReader(r, MaxArchiveEntrySize+1))
+		if err != nil {
+			return err
+		}
+		if len(b) > MaxArchiveEntrySize {
+			return fmt.Errorf("%w. name=%s", ErrArchiveEntryTooLarge, path.Join(root, name))
+		}
+		if candidates[root] == nil {
+			candidates[root] = make(map[string][]byte)
+		}
+		candidates[root][name] = b
+		return nil
+	}
+
+	switch format {
+	case FormatTarGz:
+		file, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		tr := tar.NewReader(gz)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := readEntry(header.Name, tr); err != nil {
+				return nil, err
+			}
+		}
+
+	case FormatZip:
+		zr, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		for _, entry := range zr.File {
+			if entry.FileInfo().IsDir() {
+				continue
+			}
+			r, err := entry.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = readEntry(entry.Name, r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported archive format. path=%s", p)
+	}
+
+	files := candidates[""]
+	if len(roots) == 1 {
+		for root := range roots {
+			files = candidates[root]
+		}
+	}
+	if files == nil {
+		files = make(map[string][]byte)
+	}
+	return files, nil
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type archiveEntry struct {
+	Name    string
+	Content string
+}
+
+func writeTarGz(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.Name, Mode: 0644, Size: int64(len(entry.Content)), Typeflag: tar.TypeReg}
+		if entry.Name[len(entry.Name)-1] == '/' {
+			header = &tar.Header{Name: entry.Name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.Content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := os.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func writeZip(t *testing.T, entries []archiveEntry) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.Content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "archive.zip")
+	if err := os.WriteFile(p, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestArchiveEntryName(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantRoot string
+		wantName string
+	}{
+		{name: "flows.json", wantRoot: "", wantName: "flows.json"},
+		{name: "./flows.json", wantRoot: "", wantName: "flows.json"},
+		{name: "/flows.json", wantRoot: "", wantName: "flows.json"},
+		{name: "bundle/flows.json", wantRoot: "bundle", wantName: "flows.json"},
+		{name: "./bundle/lib/package.json", wantRoot: "bundle", wantName: "lib/package.json"},
+		{name: "../../flows.json", wantRoot: "", wantName: "flows.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, name := archiveEntryName(tt.name)
+			if root != tt.wantRoot || name != tt.wantName {
+				t.Errorf("archiveEntryName() = (%q, %q), want (%q, %q)", root, name, tt.wantRoot, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestReadArchiveFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "files in the root",
+			entries: []archiveEntry{{"flows.json", "flows"}, {"package.json", "package"}, {"README.md", "readme"}},
+			want:    map[string]string{"flows.json": "flows", "package.json": "package"},
+		},
+		{
+			name:    "files in a single top level directory",
+			entries: []archiveEntry{{"bundle/", ""}, {"bundle/flows.json", "flows"}, {"bundle/package.json", "package"}},
+			want:    map[string]string{"flows.json": "flows", "package.json": "package"},
+		},
+		{
+			name:    "nested file next to a root file",
+			entries: []archiveEntry{{"package.json", "root"}, {"flows.json", "flows"}, {"lib/package.json", "nested"}},
+			want:    map[string]string{"flows.json": "flows", "package.json": "root"},
+		},
+		{
+			name:    "nested file in a single top level directory",
+			entries: []archiveEntry{{"package/package.json", "root"}, {"package/lib/package.json", "nested"}},
+			want:    map[string]string{"package.json": "root"},
+		},
+		{
+			name:    "multiple top level directories",
+			entries: []archiveEntry{{"a/flows.json", "a"}, {"b/flows.json", "b"}},
+			want:    map[string]string{},
+		},
+		{
+			name:    "macOS metadata is ignored",
+			entries: []archiveEntry{{"bundle/flows.json", "flows"}, {"__MACOSX/bundle/._flows.json", "meta"}},
+			want:    map[string]string{"flows.json": "flows"},
+		},
+		{
+			name:    "missing files",
+			entries: []archiveEntry{{"README.md", "readme"}},
+			want:    map[string]string{},
+		},
+		{
+			name:    "duplicate entries",
+			entries: []archiveEntry{{"flows.json", "a"}, {"./flows.json", "b"}},
+			wantErr: true,
+		},
+	}
+
+	writers := map[string]func(*testing.T, []archiveEntry) string{
+		"tar.gz": writeTarGz,
+		"zip":    writeZip,
+	}
+	for format, write := range writers {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				p := write(t, tt.entries)
+				files, err := ReadArchiveFiles(p, "flows.json", "package.json")
+				if tt.wantErr {
+					if err == nil {
+						t.Fatalf("ReadArchiveFiles() expected an error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ReadArchiveFiles() unexpected error. %v", err)
+				}
+				got := make(map[string]string, len(files))
+				for name, b := range files {
+					got[name] = string(b)
+				}
+				if !maps.Equal(got, tt.want) {
+					t.Errorf("ReadArchiveFiles() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestReadArchiveFilesTooLarge(t *testing.T) {
+	p := writeTarGz(t, []archiveEntry{{"flows.json", string(make([]byte, MaxArchiveEntrySize+1))}})
+	if _, err := ReadArchiveFiles(p, "flows.json"); !errors.Is(err, ErrArchiveEntryTooLarge) {
+		t.Errorf("ReadArchiveFiles() error = %v, want %v", err, ErrArchiveEntryTooLarge)
+	}
+}
+
+func TestReadArchiveFilesUnsupportedFormat(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "flows.json")
+	if err := os.WriteFile(p, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadArchiveFiles(p, "flows.json"); err == nil {
+		t.Errorf("ReadArchiveFiles() expected an error")
+	}
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	paths := map[ArchiveFormat]string{
+		FormatTarGz: writeTarGz(t, []archiveEntry{{"flows.json", "[]"}}),
+		FormatZip:   writeZip(t, []archiveEntry{{"flows.json", "[]"}}),
+	}
+	for _, want := range slices.Sorted(maps.Keys(paths)) {
+		got, err := DetectArchiveFormat(paths[want])
+		if err != nil || got != want {
+			t.Errorf("DetectArchiveFormat() = %v, %v, want %v", got, err, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package variables
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrUnresolved = errors.New("unresolved variables")
+
+var placeholderPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Source looks up the value of a variable
+type Source func(name string) (string, bool)
+
+// MapSource returns a source which looks up variables in a map
+func MapSource(values map[string]string) Source {
+	return func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+}
+
+// Resolver replaces ${NAME} placeholders in a json document
+type Resolver struct {
+	// Sources are checked in order, so the first source has the highest precedence
+	Sources []Source
+
+	// Allowed contains the patterns (e.g. TEDGE_*) of variables which can be substituted
+	Allowed []string
+
+	// Strict returns an error if any placeholders can not be resolved
+	Strict bool
+}
+
+func (r *Resolver) IsAllowed(name string) bool {
+	for _, pattern := range r.Allowed {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) Lookup(name string) (string, bool) {
+	if !r.IsAllowed(name) {
+		return "", false
+	}
+	for _, source := range r.Sources {
+		if v, ok := source(name); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Resolve replaces all of the placeholders which can be resolved. The values are json escaped
+// as the placeholders are expected to be inside json strings. The names of the placeholders
+// which could not be resolved are also returned
+func (r *Resolver) Resolve(b []byte) ([]byte, []string, error) {
+	unresolved := make(map[string]struct{})
+	var encodeErr error
+	out := placeholderPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+		name := string(placeholderPattern.FindSubmatch(match)[1])
+		value, ok := r.Lookup(name)
+		if !ok {
+			unresolved[name] = struct{}{}
+			return match
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			encodeErr = err
+			return match
+		}
+		return encoded[1 : len(encoded)-1]
+	})
+	if encodeErr != nil {
+		return nil, nil, encodeErr
+	}
+
+	names := make([]string, 0, len(unresolved))
+	for name := range unresolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if r.Strict && len(names) > 0 {
+		return nil, names, fmt.Errorf("%w. names=%s", ErrUnresolved, strings.Join(names, ","))
+	}
+	return out, names, nil
+}
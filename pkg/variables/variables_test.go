@@ -0,0 +1,106 @@
+package variables
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	values := MapSource(map[string]string{
+		"TEDGE_DEVICE_ID":   "device01",
+		"TEDGE_MQTT_HOST":   "127.0.0.1",
+		"TEDGE_DESCRIPTION": `say "hi"\n`,
+		"SECRET":            "password",
+	})
+	tests := []struct {
+		name           string
+		resolver       Resolver
+		input          string
+		want           string
+		wantUnresolved []string
+		wantErr        bool
+	}{
+		{
+			name:     "replace placeholders",
+			resolver: Resolver{Sources: []Source{values}, Allowed: []string{"TEDGE_*"}},
+			input:    `{"topic":"te/device/${TEDGE_DEVICE_ID}","broker":"${TEDGE_MQTT_HOST}"}`,
+			want:     `{"topic":"te/device/device01","broker":"127.0.0.1"}`,
+		},
+		{
+			name:     "values are json escaped",
+			resolver: Resolver{Sources: []Source{values}, Allowed: []string{"TEDGE_*"}},
+			input:    `{"info":"${TEDGE_DESCRIPTION}"}`,
+			want:     `{"info":"say \"hi\"\\n"}`,
+		},
+		{
+			name:           "variables which are not allowed are not substituted",
+			resolver:       Resolver{Sources: []Source{values}, Allowed: []string{"TEDGE_*"}},
+			input:          `{"password":"${SECRET}"}`,
+			want:           `{"password":"${SECRET}"}`,
+			wantUnresolved: []string{"SECRET"},
+		},
+		{
+			name:           "no allowed variables",
+			resolver:       Resolver{Sources: []Source{values}},
+			input:          `{"id":"${TEDGE_DEVICE_ID}"}`,
+			want:           `{"id":"${TEDGE_DEVICE_ID}"}`,
+			wantUnresolved: []string{"TEDGE_DEVICE_ID"},
+		},
+		{
+			name:           "unknown variables are kept",
+			resolver:       Resolver{Sources: []Source{values}, Allowed: []string{"*"}},
+			input:          `{"a":"${UNKNOWN_B}","b":"${UNKNOWN_A}","c":"${UNKNOWN_B}"}`,
+			want:           `{"a":"${UNKNOWN_B}","b":"${UNKNOWN_A}","c":"${UNKNOWN_B}"}`,
+			wantUnresolved: []string{"UNKNOWN_A", "UNKNOWN_B"},
+		},
+		{
+			name: "first source takes precedence",
+			resolver: Resolver{
+				Sources: []Source{MapSource(map[string]string{"TEDGE_DEVICE_ID": "override"}), values},
+				Allowed: []string{"TEDGE_*"},
+			},
+			input: `{"id":"${TEDGE_DEVICE_ID}"}`,
+			want:  `{"id":"override"}`,
+		},
+		{
+			name:     "other placeholder formats are ignored",
+			resolver: Resolver{Sources: []Source{values}, Allowed: []string{"*"}, Strict: true},
+			input:    `{"a":"$TEDGE_DEVICE_ID","b":"${1INVALID}","c":"{{TEDGE_DEVICE_ID}}"}`,
+			want:     `{"a":"$TEDGE_DEVICE_ID","b":"${1INVALID}","c":"{{TEDGE_DEVICE_ID}}"}`,
+		},
+		{
+			name:     "strict mode resolved",
+			resolver: Resolver{Sources: []Source{values}, Allowed: []string{"TEDGE_*"}, Strict: true},
+			input:    `{"id":"${TEDGE_DEVICE_ID}"}`,
+			want:     `{"id":"device01"}`,
+		},
+		{
+			name:           "strict mode unresolved",
+			resolver:       Resolver{Sources: []Source{values}, Allowed: []string{"TEDGE_*"}, Strict: true},
+			input:          `{"id":"${TEDGE_DEVICE_ID}","password":"${SECRET}"}`,
+			wantUnresolved: []string{"SECRET"},
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved, err := tt.resolver.Resolve([]byte(tt.input))
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnresolved) {
+					t.Errorf("Resolve() error = %v, want %v", err, ErrUnresolved)
+				}
+			} else if err != nil {
+				t.Fatalf("Resolve() unexpected error. %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Resolve() = %s, want %s", got, tt.want)
+			}
+			if len(unresolved) != 0 || len(tt.wantUnresolved) != 0 {
+				if !slices.Equal(unresolved, tt.wantUnresolved) {
+					t.Errorf("Resolve() unresolved = %v, want %v", unresolved, tt.wantUnresolved)
+				}
+			}
+		})
+	}
+}